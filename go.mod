@@ -3,7 +3,9 @@ module github.com/Azer0s/sane
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/hacdias/fileutils v1.0.0
 	github.com/mitchellh/go-homedir v1.1.0
 	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,322 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//BackupFile the state of a destination before sane changed it
+type BackupFile struct {
+	Destination string    `json:"destination"`
+	Hash        string    `json:"hash"`
+	Meta        *FileMeta `json:"meta,omitempty"`
+	Privileged  bool      `json:"privileged,omitempty"`
+}
+
+//BackupGeneration the state of all destinations touched by one apply/remove/restore
+type BackupGeneration struct {
+	Generation int          `json:"generation"`
+	Repo       Repo         `json:"repo"`
+	Action     string       `json:"action"`
+	Created    time.Time    `json:"created"`
+	Files      []BackupFile `json:"files"`
+	// Ledger the ledger entry of the config before the action, nil if it wasn't applied
+	Ledger *LedgerEntry `json:"ledger,omitempty"`
+	// LedgerRecorded generations of older versions don't record the ledger entry
+	LedgerRecorded bool `json:"ledger_recorded,omitempty"`
+}
+
+func backupObject(hash string) string {
+	return GetSaneFile(filepath.Join("backups", "objects", hash))
+}
+
+func generationDir(repo Repo) string {
+	return GetSaneFile(filepath.Join("backups", strings.TrimPrefix(GetRepoFolder(repo), "./")))
+}
+
+//storeBackup store the content of a file in the backup store. Returns "" if the file doesn't exist.
+func storeBackup(file string) (string, error) {
+	b, err := readDestination(file)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if DryRun {
+		plan("backup", file)
+		return ContentHash(b), nil
+	}
+
+	return storeContent(b)
+}
+
+//storeContent store content in the backup store. Returns its hash.
+func storeContent(b []byte) (string, error) {
+	hash := ContentHash(b)
+
+	if DryRun {
+		return hash, nil
+	}
+
+	obj := backupObject(hash)
+	if _, err := os.Stat(obj); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(obj), 0700); err != nil {
+		return "", err
+	}
+
+	return hash, ioutil.WriteFile(obj, b, 0600)
+}
+
+func readBackup(hash string) ([]byte, error) {
+	return ioutil.ReadFile(backupObject(hash))
+}
+
+//restoreFromBackup put a destination back to a backed up state. An empty hash means the destination didn't exist.
+func restoreFromBackup(tx *transaction, dst string, hash string, meta *FileMeta) {
+	if hash == "" {
+		err := tx.delete(dst)
+		tx.check(err, "❌  There was an error while deleting "+dst+"!")
+		return
+	}
+
+	b, err := readBackup(hash)
+	tx.check(err, "❌  Backup of "+dst+" is missing!")
+
+	err = tx.makeDir(filepath.Dir(dst))
+	tx.check(err, "❌  There was an error while creating a directory!")
+
+	err = tx.write(dst, b)
+	tx.check(err, "❌  There was an error while restoring "+dst+"!")
+
+	restoreMeta(tx, dst, meta)
+}
+
+func readGenerations(repo Repo) []BackupGeneration {
+	return readGenerationDir(generationDir(repo))
+}
+
+func readGenerationDir(dir string) []BackupGeneration {
+	generations := make([]BackupGeneration, 0)
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return generations
+	}
+	Check(err)
+
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		Check(err)
+
+		var generation BackupGeneration
+		err = json.Unmarshal(b, &generation)
+		CheckWithMessage(err, "😕  Invalid backup "+f.Name()+"!")
+
+		generations = append(generations, generation)
+	}
+
+	sort.SliceStable(generations, func(i, j int) bool {
+		return generations[i].Generation < generations[j].Generation
+	})
+
+	return generations
+}
+
+//writeGeneration record the previous state of the destinations touched by an action as a new generation
+func writeGeneration(repo Repo, action string, files []BackupFile) {
+	if DryRun || len(files) == 0 {
+		return
+	}
+
+	generations := readGenerations(repo)
+	generation := BackupGeneration{
+		Generation: 1,
+		Repo:       repo,
+		Action:     action,
+		Created:    time.Now(),
+		Files:      files,
+	}
+
+	// the ledger isn't written before the action is done
+	generation.LedgerRecorded = true
+	if entry, ok := ReadLedger().Entries[GetRepoString(repo)]; ok {
+		generation.Ledger = &entry
+	}
+
+	if len(generations) != 0 {
+		generation.Generation = generations[len(generations)-1].Generation + 1
+	}
+
+	err := os.MkdirAll(generationDir(repo), 0700)
+	Check(err)
+
+	b, err := json.MarshalIndent(generation, "", "  ")
+	Check(err)
+
+	err = ioutil.WriteFile(filepath.Join(generationDir(repo), strconv.Itoa(generation.Generation)+".json"), b, 0600)
+	Check(err)
+}
+
+//snapshot back up the current state of the given destinations
+func snapshot(dsts []string) []BackupFile {
+	files := make([]BackupFile, 0)
+
+	for _, dst := range dsts {
+		hash, err := storeBackup(dst)
+		CheckWithMessage(err, "❌  There was an error while backing up "+dst+"!")
+
+		meta, err := readMeta(dst)
+		CheckWithMessage(err, "❌  There was an error while reading "+dst+"!")
+
+		files = append(files, BackupFile{Destination: dst, Hash: hash, Meta: meta, Privileged: isPrivileged(dst)})
+	}
+
+	return files
+}
+
+func printGenerations(key string, generations []BackupGeneration) {
+	fmt.Println("⚡️ " + key)
+
+	for _, generation := range generations {
+		fmt.Printf("\t#%-3d %s  %-7s %d file(s)\n", generation.Generation, generation.Created.Format("2006-01-02 15:04"), generation.Action, len(generation.Files))
+	}
+}
+
+//ListBackups list the backup generations of a config or of all configs if repo is nil
+func ListBackups(repo *Repo) {
+	if repo != nil {
+		printGenerations(GetRepoString(*repo), readGenerations(*repo))
+		return
+	}
+
+	dirs, err := ioutil.ReadDir(GetSaneFile("backups"))
+	if os.IsNotExist(err) {
+		fmt.Println("🤷  No backups!")
+		return
+	}
+	Check(err)
+
+	for _, dir := range dirs {
+		if !dir.IsDir() || dir.Name() == "objects" {
+			continue
+		}
+
+		generations := readGenerationDir(GetSaneFile(filepath.Join("backups", dir.Name())))
+
+		if len(generations) != 0 {
+			printGenerations(GetRepoString(generations[0].Repo), generations)
+		}
+	}
+}
+
+//restoredEntry the ledger entry of a config after restoring a generation. Returns false if the config isn't applied anymore.
+func restoredEntry(current LedgerEntry, target BackupGeneration) (LedgerEntry, bool) {
+	restored := make(map[string]BackupFile)
+	for _, f := range target.Files {
+		restored[f.Destination] = f
+	}
+
+	entry := current
+	if target.LedgerRecorded {
+		if target.Ledger == nil {
+			entry = LedgerEntry{Repo: current.Repo, Mode: current.Mode, Files: make([]LedgerFile, 0), Directories: current.Directories}
+		} else {
+			entry = *target.Ledger
+		}
+	}
+
+	files := make([]LedgerFile, 0)
+	for _, f := range entry.Files {
+		b, ok := restored[f.Destination]
+
+		// older generations don't know the ledger, files which are back to their original aren't managed anymore
+		if !target.LedgerRecorded && ok && b.Hash == f.Backup {
+			continue
+		}
+
+		files = append(files, f)
+	}
+
+	// files applied after the generation aren't touched by the restore
+	for _, f := range current.Files {
+		if _, ok := restored[f.Destination]; !ok {
+			if _, found := findLedgerFile(files, f.Destination); !found {
+				files = append(files, f)
+			}
+		}
+	}
+
+	entry.Files = files
+	return entry, len(files) != 0 || (target.LedgerRecorded && target.Ledger != nil)
+}
+
+//RestoreBackup restore the destinations of a config to a backup generation (the latest if generation is 0)
+func RestoreBackup(repo Repo, generation int) {
+	generations := readGenerations(repo)
+
+	if len(generations) == 0 {
+		fmt.Println("🤷  No backups of " + GetRepoString(repo) + "!")
+		os.Exit(1)
+	}
+
+	target := generations[len(generations)-1]
+
+	if generation != 0 {
+		found := false
+		for _, g := range generations {
+			if g.Generation == generation {
+				target = g
+				found = true
+			}
+		}
+
+		if !found {
+			fmt.Println("❌  Generation " + strconv.Itoa(generation) + " not found!")
+			os.Exit(1)
+		}
+	}
+
+	dsts := make([]string, 0)
+	for _, f := range target.Files {
+		dsts = append(dsts, f.Destination)
+
+		if f.Privileged {
+			markPrivileged(f.Destination)
+		}
+	}
+
+	// the current state becomes a generation of its own so the restore can be undone
+	current := snapshot(dsts)
+
+	tx := &transaction{}
+	for _, f := range target.Files {
+		restoreFromBackup(tx, f.Destination, f.Hash, f.Meta)
+	}
+
+	writeGeneration(repo, "restore", current)
+
+	ledger := ReadLedger()
+	if entry, ok := restoredEntry(ledger.Entries[GetRepoString(repo)], target); ok {
+		ledger.Entries[GetRepoString(repo)] = entry
+	} else {
+		delete(ledger.Entries, GetRepoString(repo))
+	}
+	WriteLedger(ledger)
+
+	fmt.Println("⏪  Restored generation #" + strconv.Itoa(target.Generation) + " of " + GetRepoString(repo))
+}
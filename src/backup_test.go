@@ -0,0 +1,52 @@
+package src
+
+import (
+	"testing"
+)
+
+func TestRestoredEntry(t *testing.T) {
+	current := LedgerEntry{Mode: "config", Files: []LedgerFile{
+		{Destination: "/a", Hash: "a2", Backup: "a1"},
+		{Destination: "/b", Hash: "b2", Backup: "b1"},
+	}}
+	applied := LedgerEntry{Mode: "config", Files: []LedgerFile{{Destination: "/a", Hash: "a1", Backup: "a0"}}}
+
+	tests := []struct {
+		name   string
+		target BackupGeneration
+		files  []string
+		ok     bool
+	}{
+		{"before the first apply", BackupGeneration{Files: []BackupFile{{Destination: "/a", Hash: "a1"}, {Destination: "/b", Hash: "b1"}}, LedgerRecorded: true}, []string{}, false},
+		{"before a later apply", BackupGeneration{Files: []BackupFile{{Destination: "/a", Hash: "a1"}}, Ledger: &applied, LedgerRecorded: true}, []string{"/a", "/b"}, true},
+		{"without ledger, back to the original", BackupGeneration{Files: []BackupFile{{Destination: "/a", Hash: "a1"}}}, []string{"/b"}, true},
+		{"without ledger, something else", BackupGeneration{Files: []BackupFile{{Destination: "/a", Hash: "x"}}}, []string{"/a", "/b"}, true},
+		{"without ledger, all originals", BackupGeneration{Files: []BackupFile{{Destination: "/a", Hash: "a1"}, {Destination: "/b", Hash: "b1"}}}, []string{}, false},
+	}
+
+	for _, test := range tests {
+		entry, ok := restoredEntry(current, test.target)
+
+		files := make([]string, 0)
+		for _, f := range entry.Files {
+			files = append(files, f.Destination)
+		}
+
+		if ok != test.ok || len(files) != len(test.files) {
+			t.Errorf("%s = %v, %v, want %v, %v", test.name, files, ok, test.files, test.ok)
+			continue
+		}
+
+		for i := range files {
+			if files[i] != test.files[i] {
+				t.Errorf("%s = %v, want %v", test.name, files, test.files)
+			}
+		}
+	}
+
+	// the restored entry is the one before the apply, not the current one
+	entry, _ := restoredEntry(current, BackupGeneration{Files: []BackupFile{{Destination: "/a", Hash: "a1"}}, Ledger: &applied, LedgerRecorded: true})
+	if f, _ := findLedgerFile(entry.Files, "/a"); f.Hash != "a1" {
+		t.Errorf("restored /a = %+v, want the ledger file of the generation", f)
+	}
+}
@@ -0,0 +1,264 @@
+package src
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//DefaultPrefix the folder binaries are installed into if the sanefile doesn't set a prefix
+const DefaultPrefix = "$HOME/.local/bin"
+
+//BinaryEntry a release binary of a config
+type BinaryEntry struct {
+	Name        string
+	URL         string
+	Path        string
+	Sha256      string
+	Destination string
+}
+
+//binaryData the data URL templates are rendered with
+type binaryData struct {
+	TemplateData
+	Name    string
+	Version string
+}
+
+func renderString(name string, text string, data interface{}) string {
+	tpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		CheckCouldntParse(err, "Invalid template \""+text+"\"!")
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		CheckCouldntParse(err, "Couldn't render \""+text+"\": "+err.Error())
+	}
+
+	return buf.String()
+}
+
+//extractChecksum get the sha256 of a binary, either one for all platforms or one per <os>_<arch>
+func extractChecksum(v interface{}, name string) string {
+	switch val := v.(type) {
+	case string:
+		return strings.ToLower(val)
+	case map[interface{}]interface{}:
+		if sum, ok := val[runtime.GOOS+"_"+runtime.GOARCH]; ok {
+			return strings.ToLower(sum.(string))
+		}
+	}
+
+	fmt.Println("❌  No sha256 of " + name + " for " + runtime.GOOS + "_" + runtime.GOARCH + "!")
+	os.Exit(1)
+	return ""
+}
+
+func extractBinaries(m map[string]interface{}, data TemplateData) []BinaryEntry {
+	entries := make([]BinaryEntry, 0)
+
+	prefix := DefaultPrefix
+	if p, ok := m["prefix"]; ok {
+		prefix = p.(string)
+	}
+	prefix = os.ExpandEnv(prefix)
+
+	binaries, ok := m["binaries"]
+	if !ok {
+		fmt.Println("❌  Binaries not found!")
+		os.Exit(1)
+	}
+
+	for _, v := range binaries.([]interface{}) {
+		b := v.(map[interface{}]interface{})
+
+		name, _ := b["name"].(string)
+		url, _ := b["url"].(string)
+		if name == "" || url == "" {
+			CheckCouldntParse(errors.New(""), "Binaries need a name and a url!")
+		}
+
+		d := binaryData{TemplateData: data, Name: name}
+		if version, ok := b["version"]; ok {
+			d.Version = fmt.Sprintf("%v", version)
+		}
+
+		entry := BinaryEntry{
+			Name:        name,
+			URL:         renderString(name, url, d),
+			Path:        name,
+			Sha256:      extractChecksum(b["sha256"], name),
+			Destination: filepath.Join(prefix, name),
+		}
+
+		if p, ok := b["path"]; ok {
+			entry.Path = renderString(name, p.(string), d)
+		}
+
+		if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+			entry.Destination += ".exe"
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func download(url string) ([]byte, error) {
+	client := http.Client{Timeout: 10 * time.Minute}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+//matchesMember check if a file of an archive is the binary, either by its path or by its base name
+func matchesMember(name string, member string) bool {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	return name == member || (!strings.Contains(member, "/") && path.Base(name) == member)
+}
+
+//extractBinary get the binary out of a downloaded tar.gz or zip archive. Other downloads are the binary itself.
+func extractBinary(b []byte, url string, member string) ([]byte, error) {
+	switch {
+	case strings.HasSuffix(url, ".tar.gz") || strings.HasSuffix(url, ".tgz"):
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+
+			if hdr.Typeflag == tar.TypeReg && matchesMember(hdr.Name, member) {
+				return ioutil.ReadAll(tr)
+			}
+		}
+	case strings.HasSuffix(url, ".zip"):
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || !matchesMember(f.Name, member) {
+				continue
+			}
+
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+
+			return ioutil.ReadAll(rc)
+		}
+	default:
+		return b, nil
+	}
+
+	return nil, errors.New(member + " not found in the archive")
+}
+
+//fetchBinary download a binary, verify its checksum and extract it
+func fetchBinary(entry BinaryEntry) ([]byte, error) {
+	fmt.Println("⬇️  Downloading " + entry.Name + " from " + entry.URL + "...")
+
+	b, err := download(entry.URL)
+	if err != nil {
+		return nil, errors.New("couldn't download " + entry.URL + ": " + err.Error())
+	}
+
+	if sum := ContentHash(b); sum != entry.Sha256 {
+		return nil, errors.New("checksum of " + entry.URL + " doesn't match, expected " + entry.Sha256 + ", got " + sum)
+	}
+
+	bin, err := extractBinary(b, entry.URL, entry.Path)
+	if err != nil {
+		return nil, errors.New("couldn't extract " + entry.Name + ": " + err.Error())
+	}
+
+	return bin, nil
+}
+
+//applyBinaries install the binaries of a config. Returns the ledger entry and the backups of the previous state.
+func applyBinaries(m map[string]interface{}, repo Repo, cfg SaneConfig, entry LedgerEntry, tx *transaction) (LedgerEntry, []BackupFile) {
+	previous := entry.Files
+	entry.Files = make([]LedgerFile, 0)
+
+	binaries := extractBinaries(m, templateData(m, cfg))
+	contents := make(map[string][]byte)
+
+	// download everything before touching anything
+	for _, b := range binaries {
+		record, _ := findLedgerFile(previous, b.Destination)
+
+		if hash, err := FileHash(b.Destination); err != nil || record.Source != b.URL || hash != record.Hash {
+			if DryRun {
+				plan("download", b.URL)
+			} else {
+				bin, err := fetchBinary(b)
+				if err != nil {
+					fmt.Println("❌  " + err.Error())
+					os.Exit(1)
+				}
+
+				contents[b.Destination] = bin
+				record.Hash = ContentHash(contents[b.Destination])
+			}
+		}
+
+		record.Source = b.URL
+		record.Destination = b.Destination
+		record.Strategy = CopyStrategy
+
+		entry.Files = append(entry.Files, record)
+	}
+
+	return installFiles(repo, entry, previous, tx, func(i int, record *LedgerFile) {
+		dst := record.Destination
+
+		content, ok := contents[dst]
+		if !ok {
+			if !DryRun {
+				fmt.Println("👌  " + dst + " is up to date")
+			}
+			return
+		}
+
+		err := tx.write(dst, content)
+		tx.check(err, "❌  There was an error while installing "+dst+"!")
+
+		err = tx.chmod(dst, 0755)
+		tx.check(err, "❌  There was an error while making "+dst+" executable!")
+	})
+}
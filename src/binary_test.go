@@ -0,0 +1,228 @@
+package src
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+//releaseServer serve downloads by path and count the requests
+func releaseServer(files map[string][]byte) (*httptest.Server, map[string]int) {
+	requests := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+
+		b, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write(b)
+	}))
+
+	return server, requests
+}
+
+//useHome point the home directory to a temporary one. Returns a func which removes it.
+func useHome(t *testing.T) (string, func()) {
+	home, err := ioutil.TempDir("", "sane-home-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := os.Getenv("HOME")
+	_ = os.Setenv("HOME", home)
+	homedir.DisableCache = true
+	homedir.Reset()
+	CheckSaneDir()
+
+	return home, func() {
+		_ = os.Setenv("HOME", previous)
+		homedir.Reset()
+		_ = os.RemoveAll(home)
+	}
+}
+
+func TestExtractBinaryTarGz(t *testing.T) {
+	archive := tarGz(t, map[string]string{
+		"tool-1.0/README": "readme",
+		"tool-1.0/tool":   "binary",
+	})
+
+	for _, member := range []string{"tool", "tool-1.0/tool"} {
+		b, err := extractBinary(archive, "https://example.com/tool-1.0.tar.gz", member)
+		if err != nil || string(b) != "binary" {
+			t.Errorf("extract %s = %q, %v, want \"binary\"", member, b, err)
+		}
+	}
+
+	if _, err := extractBinary(archive, "https://example.com/tool.tgz", "other"); err == nil {
+		t.Error("extracting a missing member didn't fail")
+	}
+}
+
+func TestExtractBinaryZip(t *testing.T) {
+	archive := zipped(t, map[string]string{
+		"jq/":       "",
+		"jq/bin/jq": "binary",
+		"jq/jq.1":   "manual",
+	})
+
+	b, err := extractBinary(archive, "https://example.com/jq.zip", "bin/jq")
+	if err == nil {
+		t.Errorf("bin/jq isn't the path of the member but got %q", b)
+	}
+
+	b, err = extractBinary(archive, "https://example.com/jq.zip", "jq/bin/jq")
+	if err != nil || string(b) != "binary" {
+		t.Errorf("extract jq/bin/jq = %q, %v, want \"binary\"", b, err)
+	}
+
+	b, err = extractBinary(archive, "https://example.com/jq.zip", "jq")
+	if err != nil || string(b) != "binary" {
+		t.Errorf("extract jq = %q, %v, want \"binary\"", b, err)
+	}
+}
+
+func TestFetchBinaryChecksumMismatch(t *testing.T) {
+	server, _ := releaseServer(map[string][]byte{"/tool": []byte("binary")})
+	defer server.Close()
+
+	entry := BinaryEntry{Name: "tool", URL: server.URL + "/tool", Path: "tool", Sha256: ContentHash([]byte("other"))}
+
+	_, err := fetchBinary(entry)
+	if err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Errorf("fetch with a wrong checksum = %v, want a checksum error", err)
+	}
+
+	entry.Sha256 = ContentHash([]byte("binary"))
+	if b, err := fetchBinary(entry); err != nil || string(b) != "binary" {
+		t.Errorf("fetch = %q, %v, want \"binary\"", b, err)
+	}
+}
+
+func TestApplyBinariesInstallRemove(t *testing.T) {
+	home, cleanup := useHome(t)
+	defer cleanup()
+
+	tool := tarGz(t, map[string]string{"tool-1.2/tool": "tool 1.2"})
+	jq := zipped(t, map[string]string{"jq/jq": "jq"})
+
+	server, requests := releaseServer(map[string][]byte{
+		"/tool-1.2-" + runtime.GOOS + ".tar.gz": tool,
+		"/jq.zip":                               jq,
+	})
+	defer server.Close()
+
+	prefix := filepath.Join(home, "bin")
+	m := map[string]interface{}{
+		"mode":   "binary",
+		"prefix": prefix,
+		"binaries": []interface{}{
+			map[interface{}]interface{}{
+				"name":    "tool",
+				"version": 1.2,
+				"url":     server.URL + "/tool-{{ .Version }}-{{ .OS }}.tar.gz",
+				"sha256":  map[interface{}]interface{}{runtime.GOOS + "_" + runtime.GOARCH: ContentHash(tool)},
+			},
+			map[interface{}]interface{}{
+				"name":   "jq",
+				"url":    server.URL + "/jq.zip",
+				"sha256": ContentHash(jq),
+			},
+		},
+	}
+
+	repo := Repo{User: "user", Name: "tools"}
+	entry, _ := applyBinaries(m, repo, SaneConfig{}, LedgerEntry{Repo: repo}, &transaction{})
+
+	for name, content := range map[string]string{"tool": "tool 1.2", "jq": "jq"} {
+		dst := filepath.Join(prefix, name)
+		if runtime.GOOS == "windows" {
+			dst += ".exe"
+		}
+
+		b, err := ioutil.ReadFile(dst)
+		if err != nil || string(b) != content {
+			t.Errorf("%s = %q, %v, want %q", dst, b, err, content)
+		}
+
+		if fi, err := os.Stat(dst); runtime.GOOS != "windows" && (err != nil || fi.Mode().Perm() != 0755) {
+			t.Errorf("%s isn't executable", dst)
+		}
+	}
+
+	// up to date binaries aren't downloaded again
+	entry, _ = applyBinaries(m, repo, SaneConfig{}, entry, &transaction{})
+	for path, n := range requests {
+		if n != 1 {
+			t.Errorf("%s was downloaded %d times, want once", path, n)
+		}
+	}
+
+	removeConfig(entry, &transaction{})
+	removeEmptyDirs(entry.Directories)
+
+	if _, err := os.Stat(prefix); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after remove: %v", prefix, err)
+	}
+}
@@ -0,0 +1,81 @@
+package src
+
+import (
+	"strings"
+)
+
+//DefaultComment the comment prefix of block markers if none is set
+const DefaultComment = "#"
+
+func blockMarkers(name string, comment string) (string, string) {
+	return comment + " BEGIN sane " + name, comment + " END sane " + name
+}
+
+//findBlock get the line indices of the begin and end marker of a block
+func findBlock(lines []string, name string, comment string) (int, int, bool) {
+	begin, end := blockMarkers(name, comment)
+	start := -1
+
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case begin:
+			start = i
+		case end:
+			if start >= 0 {
+				return start, i, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+//insertBlock put content between the markers of a block. An existing block is updated in place, otherwise it's appended.
+func insertBlock(existing []byte, content []byte, name string, comment string) []byte {
+	begin, end := blockMarkers(name, comment)
+
+	block := []string{begin}
+	if body := strings.TrimRight(string(content), "\n"); body != "" {
+		block = append(block, strings.Split(body, "\n")...)
+	}
+	block = append(block, end)
+
+	text := strings.TrimSuffix(string(existing), "\n")
+	lines := strings.Split(text, "\n")
+
+	if start, stop, ok := findBlock(lines, name, comment); ok {
+		lines = append(append(append([]string{}, lines[:start]...), block...), lines[stop+1:]...)
+		return []byte(strings.Join(lines, "\n") + "\n")
+	}
+
+	if text == "" {
+		return []byte(strings.Join(block, "\n") + "\n")
+	}
+
+	// keep the block apart from the user's content
+	return []byte(text + "\n\n" + strings.Join(block, "\n") + "\n")
+}
+
+//removeBlock remove a block including the blank line insertBlock put before it. Returns whether anything is left.
+func removeBlock(existing []byte, name string, comment string) ([]byte, bool) {
+	hadNewline := strings.HasSuffix(string(existing), "\n")
+	lines := strings.Split(strings.TrimSuffix(string(existing), "\n"), "\n")
+
+	start, stop, ok := findBlock(lines, name, comment)
+	if !ok {
+		return existing, strings.TrimSpace(string(existing)) != ""
+	}
+
+	if start > 0 && strings.TrimSpace(lines[start-1]) == "" {
+		start--
+	}
+
+	lines = append(append([]string{}, lines[:start]...), lines[stop+1:]...)
+	text := strings.Join(lines, "\n")
+
+	if text != "" && hadNewline {
+		text += "\n"
+	}
+
+	return []byte(text), strings.TrimSpace(text) != ""
+}
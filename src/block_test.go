@@ -0,0 +1,85 @@
+package src
+
+import (
+	"testing"
+)
+
+func TestInsertBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		content  string
+		comment  string
+		want     string
+	}{
+		{"empty file", "", "alias ll='ls -l'\n", "#", "# BEGIN sane a/b\nalias ll='ls -l'\n# END sane a/b\n"},
+		{"appended", "export A=1\n", "alias ll='ls -l'", "#", "export A=1\n\n# BEGIN sane a/b\nalias ll='ls -l'\n# END sane a/b\n"},
+		{"no trailing newline", "export A=1", "x", "#", "export A=1\n\n# BEGIN sane a/b\nx\n# END sane a/b\n"},
+		{
+			"updated in place",
+			"export A=1\n# BEGIN sane a/b\nold\nlines\n# END sane a/b\nexport B=2\n",
+			"new\n",
+			"#",
+			"export A=1\n# BEGIN sane a/b\nnew\n# END sane a/b\nexport B=2\n",
+		},
+		{
+			"other blocks are kept",
+			"# BEGIN sane c/d\nother\n# END sane c/d\n",
+			"mine",
+			"#",
+			"# BEGIN sane c/d\nother\n# END sane c/d\n\n# BEGIN sane a/b\nmine\n# END sane a/b\n",
+		},
+		{"empty content", "set nu\n", "", `"`, "set nu\n\n\" BEGIN sane a/b\n\" END sane a/b\n"},
+		{
+			"indented markers",
+			"  # BEGIN sane a/b\n  old\n  # END sane a/b\n",
+			"new",
+			"#",
+			"# BEGIN sane a/b\nnew\n# END sane a/b\n",
+		},
+	}
+
+	for _, test := range tests {
+		got := string(insertBlock([]byte(test.existing), []byte(test.content), "a/b", test.comment))
+		if got != test.want {
+			t.Errorf("%s: insertBlock = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRemoveBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		want     string
+		left     bool
+	}{
+		{"only the block", "# BEGIN sane a/b\nx\n# END sane a/b\n", "", false},
+		{"with the blank line before", "export A=1\n\n# BEGIN sane a/b\nx\n# END sane a/b\n", "export A=1\n", true},
+		{"in the middle", "a\n# BEGIN sane a/b\nx\n# END sane a/b\nb\n", "a\nb\n", true},
+		{"no trailing newline", "a\n\n# BEGIN sane a/b\nx\n# END sane a/b", "a", true},
+		{"missing block", "a\n", "a\n", true},
+		{"missing end marker", "a\n# BEGIN sane a/b\nx\n", "a\n# BEGIN sane a/b\nx\n", true},
+		{"other config", "# BEGIN sane c/d\nx\n# END sane c/d\n", "# BEGIN sane c/d\nx\n# END sane c/d\n", true},
+	}
+
+	for _, test := range tests {
+		got, left := removeBlock([]byte(test.existing), "a/b", "#")
+		if string(got) != test.want || left != test.left {
+			t.Errorf("%s: removeBlock = %q, %v, want %q, %v", test.name, got, left, test.want, test.left)
+		}
+	}
+}
+
+func TestBlockRoundTrip(t *testing.T) {
+	// files without a trailing newline get one, unchanged destinations are restored from the backup instead
+	for _, existing := range []string{"", "export A=1\n", "a\n\nb\n", "# BEGIN sane c/d\nx\n# END sane c/d\n"} {
+		inserted := insertBlock([]byte(existing), []byte("alias ll='ls -l'\n"), "a/b", "#")
+
+		// updating the block leaves the rest of the file alone
+		updated := insertBlock(inserted, []byte("alias la='ls -a'\n"), "a/b", "#")
+		if got, _ := removeBlock(updated, "a/b", "#"); string(got) != existing {
+			t.Errorf("removing the block from %q = %q, want the original", updated, got)
+		}
+	}
+}
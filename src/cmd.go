@@ -7,9 +7,16 @@ import (
 	"os/exec"
 	"path"
 	"regexp"
+	"strconv"
 )
 
 var versionStr = "sane version 1.0.0"
+
+//dryRunCommands the commands which accept --dry-run
+var dryRunCommands = []string{"apply", "remove", "start", "stop", "restart", "restore"}
+
+//forceCommands the commands which accept --force
+var forceCommands = []string{"apply"}
 var helpStr = `
 sane - A package manager for sane configuration
 https://github.com/Azer0s/sane
@@ -22,11 +29,33 @@ Commands:
   get <config>  	Pull a config from GitHub.
   purge <config>	Purge a pulled config from disk.
 
-  start <config>	Starts an application specified by a sanefile.
-  stop <config>		Stops an application specified by a sanefile.
-
-  apply <config>	Applies a configuration specified by a sanefile.
-  remove <config>	Removes a configuration specified by a sanefile.
+  start <config> [container...] [--keep-going] [--dry-run]
+                	Starts an application specified by a sanefile.
+  stop <config> [container...] [--keep-going] [--dry-run]
+                	Stops an application specified by a sanefile.
+  stop --all [--keep-going]
+                	Stops every running application.
+  restart <config> [container...] [--keep-going] [--dry-run]
+                	Restarts an application specified by a sanefile.
+  prune [--dry-run] [--yes]
+                	Removes leftover containers, networks, volumes and images.
+  ports <config>	Lists the endpoints of a running application.
+  env <config> [--format shell|dotenv|json]
+                	Prints the outputs of a running application.
+  exec <config> [container] -- <cmd...>
+                	Runs a command in a container of an application.
+  shell <config> [container]
+                	Opens a shell in a container of an application.
+
+  apply <config> [--dry-run] [--force]
+                	Applies a configuration specified by a sanefile.
+  remove <config> [--dry-run]
+                	Removes a configuration specified by a sanefile.
+  diff <config>		Shows the changes between a config and its destinations.
+  status        	Lists applied configs and their drift.
+  backups [config]	Lists the backup generations of all or one config.
+  restore <config> [--generation N] [--dry-run]
+                	Restores the destinations of a config from a backup.
 
   list        		Lists available configs.
   aliases       	Lists all aliases.
@@ -51,6 +80,10 @@ func Cmd() {
 	args := os.Args[1:]
 	cfg := ReadConfig()
 
+	if cfg.Elevation != "" {
+		Elevation = cfg.Elevation
+	}
+
 	home, err := homedir.Dir()
 	Check(err)
 
@@ -65,6 +98,21 @@ func Cmd() {
 
 	command := args[0]
 
+	switch command {
+	case "prune":
+		flags, dryRun := ExtractFlag(args[1:], "--dry-run")
+		_, yes := ExtractFlag(flags, "--yes")
+		Prune(dryRun, yes)
+		os.Exit(0)
+
+	case "stop":
+		if flags, all := ExtractFlag(args[1:], "--all"); all {
+			_, keepGoing := ExtractFlag(flags, "--keep-going")
+			ReportFailures("stop", StopAll(keepGoing))
+			os.Exit(0)
+		}
+	}
+
 	if len(args) == 1 {
 		switch command {
 		case "-h", "--help":
@@ -77,21 +125,11 @@ func Cmd() {
 			for _, repo := range cfg.Repos {
 				topics := GetTopicEmojis(repo.Topics)
 
-				branch := ""
-
-				if repo.Branch == "" {
-					if repo.Tag != "" {
-						branch = "@" + repo.Tag
-					}
-				} else {
-					branch = "/" + repo.Branch
-				}
-
 				if len(topics) != 0 {
 					topics = "\n\t" + topics
 				}
 
-				fmt.Println("⚡️ " + repo.User + "/" + repo.Name + branch + topics)
+				fmt.Println("⚡️ " + GetRepoString(repo) + topics)
 			}
 
 		case "aliases":
@@ -99,6 +137,12 @@ func Cmd() {
 				fmt.Println("🎭  " + k + " => " + v)
 			}
 
+		case "status":
+			PrintStatus(home)
+
+		case "backups":
+			ListBackups(nil)
+
 		case "rmaliases":
 			cfg.Aliases = make(map[string]string)
 			WriteConfig(cfg)
@@ -125,6 +169,21 @@ func Cmd() {
 		repo = GetRepoFromString(args[1])
 	}
 
+	containers, keepGoing := ExtractFlag(args[2:], "--keep-going")
+
+	if ContainsString(forceCommands, command) {
+		containers, Force = ExtractFlag(containers, "--force")
+	}
+
+	// other commands don't change anything, their output must stay clean (eval $(sane env ...))
+	if ContainsString(dryRunCommands, command) {
+		containers, DryRun = ExtractFlag(containers, "--dry-run")
+	}
+
+	if DryRun {
+		fmt.Println("📋  Dry run, nothing will be changed")
+	}
+
 	switch command {
 	case "get":
 		cfg = PullRepo(repo, home, cfg)
@@ -136,18 +195,68 @@ func Cmd() {
 	case "start":
 		cfg = AutoPullRepo(cfg, repo, home)
 		fmt.Println("🚀  Starting " + args[1] + "...")
-		StartConfig(repo, home)
+		ReportFailures("start", StartConfig(repo, home, containers, keepGoing))
 	case "stop":
 		fmt.Println("✋  Stopping " + args[1] + "...")
-		StopConfig(repo, home)
+		ReportFailures("stop", StopConfig(repo, home, containers, keepGoing))
+	case "restart":
+		cfg = AutoPullRepo(cfg, repo, home)
+		fmt.Println("🔁  Restarting " + args[1] + "...")
+		ReportFailures("restart", RestartConfig(repo, home, containers, keepGoing))
+	case "ports":
+		PrintPorts(repo)
+	case "env":
+		_, format := ExtractFlagValue(args[2:], "--format", FormatShell)
+		PrintEnv(repo, home, format)
+	case "exec":
+		container := ""
+		command := make([]string, 0)
+
+		if len(args) > 2 {
+			container = args[2]
+			command = args[3:]
+		}
+
+		for i, arg := range args[2:] {
+			if arg == "--" {
+				if i == 0 {
+					container = ""
+				}
+				command = args[3+i:]
+				break
+			}
+		}
+
+		if len(command) == 0 {
+			fmt.Println("❌  No command specified!")
+			os.Exit(1)
+		}
+
+		ExecContainer(repo, home, container, command)
+	case "shell":
+		container := ""
+		if len(args) > 2 {
+			container = args[2]
+		}
+
+		ShellContainer(repo, home, container)
 	case "apply":
 		cfg = AutoPullRepo(cfg, repo, home)
 		fmt.Println("✍️  ​Applying config " + args[1] + "...")
 		DoConfig(repo, home, cfg, APPLY)
 	case "remove":
-		cfg = AutoPullRepo(cfg, repo, home)
 		fmt.Println("💣  Removing config... ")
 		DoConfig(repo, home, cfg, REMOVE)
+	case "diff":
+		DiffConfig(repo, home, cfg)
+	case "backups":
+		ListBackups(&repo)
+	case "restore":
+		_, generation := ExtractFlagValue(containers, "--generation", "0")
+		n, err := strconv.Atoi(generation)
+		CheckWithMessage(err, "❌  Invalid generation \""+generation+"\"!")
+
+		RestoreBackup(repo, n)
 	case "alias":
 		fmt.Println("🤫  Aliasing " + args[1] + " to " + args[2])
 		cfg.Aliases[args[2]] = args[1]
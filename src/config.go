@@ -27,8 +27,18 @@ type Repo struct {
 
 // SaneConfig config for sane
 type SaneConfig struct {
-	Repos   []Repo            `json:"repos"`
-	Aliases map[string]string `json:"aliases"`
+	Repos     []Repo            `json:"repos"`
+	Aliases   map[string]string `json:"aliases"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Elevation string            `json:"elevation,omitempty"`
+}
+
+//GetSaneFile get the path of a file in the .sane directory
+func GetSaneFile(name string) string {
+	home, err := homedir.Dir()
+	Check(err)
+
+	return path.Join(home, "./.sane/", name)
 }
 
 //CheckSaneDir Checks if the .sane directory exists. Creates it if it doesn't.
@@ -40,11 +50,11 @@ func CheckSaneDir() {
 
 	if _, err := os.Stat(home); os.IsNotExist(err) {
 		// $HOME/.sane does not exist
-		err := os.Mkdir(home, 0777)
+		err := os.Mkdir(home, 0755)
 		Check(err)
 
 		template := []byte("{\"repos\":[],\"aliases\":{}}")
-		err = ioutil.WriteFile(path.Join(home, "./config.json"), template, 0777)
+		err = ioutil.WriteFile(path.Join(home, "./config.json"), template, 0600)
 		Check(err)
 	}
 }
@@ -69,18 +79,22 @@ func ReadConfig() SaneConfig {
 
 //WriteConfig the config
 func WriteConfig(config SaneConfig) {
+	if DryRun {
+		return
+	}
+
 	home, err := homedir.Dir()
 	Check(err)
 
 	repoFile := path.Join(home, "./.sane/config.json")
 
-	_ = os.Remove(repoFile)
-	f, _ := os.Create(repoFile)
-	_ = f.Close()
-
 	b, err := json.Marshal(config)
 	Check(err)
 
-	err = ioutil.WriteFile(repoFile, b, os.ModePerm)
+	err = ioutil.WriteFile(repoFile, b, 0600)
+	Check(err)
+
+	// older versions of sane created the config world writable
+	err = os.Chmod(repoFile, 0600)
 	Check(err)
 }
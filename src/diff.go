@@ -0,0 +1,232 @@
+package src
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+)
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+
+	return commit
+}
+
+//getUpstreamCommit get the commit the branch/tag of a config points to on GitHub
+func getUpstreamCommit(repo Repo, home string) string {
+	ref := "HEAD"
+
+	if repo.Tag != "" {
+		ref = "refs/tags/" + repo.Tag
+	} else if repo.Branch != "" {
+		ref = "refs/heads/" + repo.Branch
+	}
+
+	b, err := exec.Command("git", "-C", path.Join(home, GetRepoFolder(repo)), "ls-remote", "origin", ref, ref+"^{}").Output()
+	if err != nil {
+		return ""
+	}
+
+	commit := ""
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		fields := strings.Fields(line)
+
+		// prefer the peeled commit of annotated tags
+		if len(fields) == 2 && (commit == "" || strings.HasSuffix(fields[1], "^{}")) {
+			commit = fields[0]
+		}
+	}
+
+	return commit
+}
+
+//DiffConfig print a unified diff between the repo version and the destination of every file of a config
+func DiffConfig(repo Repo, home string, cfg SaneConfig) {
+	var m map[string]interface{}
+	files := make([]FileEntry, 0)
+	folder := path.Join(home, GetRepoFolder(repo))
+	changes := make(map[string][]MergeChange)
+
+	if entry, ok := ReadLedger().Entries[GetRepoString(repo)]; ok {
+		if entry.Mode != "config" && entry.Mode != "dotfiles" {
+			fmt.Println("❌  " + GetRepoString(repo) + " doesn't define any files!")
+			os.Exit(1)
+		}
+
+		for _, f := range entry.Files {
+			files = append(files, FileEntry{Source: f.Source, Destination: f.Destination, Strategy: f.Strategy, Template: f.Template, Format: f.Format, Comment: f.Comment, Privileged: f.Privileged})
+			changes[f.Destination] = f.Changes
+
+			if f.Template && m == nil {
+				m = readSaneYml(repo, home)
+			}
+		}
+	} else {
+		m = readSaneYml(repo, home)
+
+		if mode, ok := m["mode"]; ok && mode.(string) == "dotfiles" {
+			m = dotfilesConfig(m)
+		}
+
+		if mode, ok := m["mode"]; !ok || mode.(string) != "config" {
+			fmt.Println("❌  " + GetRepoString(repo) + " doesn't define any files!")
+			os.Exit(1)
+		}
+
+		files = extractFileConfig(m, folder, templateData(m, cfg))
+	}
+
+	rendered := renderTemplates(files, folder, templateData(m, cfg))
+
+	for _, f := range files {
+		dst := f.Destination
+		target := path.Join(folder, f.Source)
+
+		if f.Privileged {
+			markPrivileged(dst)
+		}
+
+		if _, err := os.Stat(dst); os.IsNotExist(err) {
+			fmt.Println("🗑  " + dst + " doesn't exist")
+			continue
+		}
+
+		existing, err := readDestination(dst)
+		CheckWithMessage(err, "❌  There was an error while reading "+dst+"!")
+
+		if f.Strategy == MergeStrategy {
+			content, ok := rendered[dst]
+			if !ok {
+				b, err := ioutil.ReadFile(target)
+				Check(err)
+				content = b
+			}
+
+			// diff against what the destination looks like after merging
+			merged, _, err := mergeContent(existing, content, f.Format, changes[dst])
+			if err != nil {
+				fmt.Println("❌  Couldn't merge " + f.Source + " into " + dst + ": " + err.Error())
+				os.Exit(1)
+			}
+
+			rendered[dst] = merged
+		}
+
+		if f.Strategy == BlockStrategy {
+			content, ok := rendered[dst]
+			if !ok {
+				b, err := ioutil.ReadFile(target)
+				Check(err)
+				content = b
+			}
+
+			rendered[dst] = insertBlock(existing, content, GetRepoString(repo), f.Comment)
+		}
+
+		tmps := make([]string, 0)
+		current := dst
+
+		if content, ok := rendered[dst]; ok {
+			target = tempCopy(content)
+			tmps = append(tmps, target)
+		}
+
+		// git can't read privileged destinations itself
+		if _, err := ioutil.ReadFile(dst); os.IsPermission(err) {
+			current = tempCopy(existing)
+			tmps = append(tmps, current)
+		}
+
+		cmd := exec.Command("git", "diff", "--no-index", "--", target, current)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		err = cmd.Run()
+
+		for _, tmp := range tmps {
+			_ = os.Remove(tmp)
+		}
+
+		// git diff exits with 1 if the files differ
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+				fmt.Println("❌  There was an error while diffing " + dst + "!")
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+//tempCopy write content to a temporary file git can diff
+func tempCopy(content []byte) string {
+	tmp, err := ioutil.TempFile("", "sane-")
+	Check(err)
+
+	_, err = tmp.Write(content)
+	_ = tmp.Close()
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	Check(err)
+
+	return tmp.Name()
+}
+
+//PrintStatus print applied configs and flag drifted or deleted destinations and upstream changes
+func PrintStatus(home string) {
+	ledger := ReadLedger()
+
+	keys := make([]string, 0)
+	for key := range ledger.Entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		fmt.Println("🤷  No configs applied!")
+		return
+	}
+
+	for _, key := range keys {
+		entry := ledger.Entries[key]
+		fmt.Println("⚡️ " + key + " (" + entry.Mode + ", applied " + entry.Applied.Format("2006-01-02 15:04") + ")")
+
+		for _, f := range entry.Files {
+			if f.Privileged {
+				markPrivileged(f.Destination)
+			}
+
+			hash, err := FileHash(f.Destination)
+
+			switch {
+			case os.IsNotExist(err):
+				fmt.Println("\t🗑  " + f.Destination + " was deleted")
+			case err != nil:
+				fmt.Println("\t❌  " + f.Destination + " couldn't be read")
+			case hash != f.Hash:
+				fmt.Println("\t✏️  " + f.Destination + " drifted")
+			default:
+				fmt.Println("\t✅  " + f.Destination)
+			}
+		}
+
+		if entry.Commit == "" {
+			continue
+		}
+
+		if local := GetRepoCommit(entry.Repo, home); local != "" && local != entry.Commit {
+			fmt.Println("\t🔄  pulled " + shortCommit(local) + " since apply (" + shortCommit(entry.Commit) + ")")
+		}
+
+		if upstream := getUpstreamCommit(entry.Repo, home); upstream != "" && upstream != entry.Commit {
+			fmt.Println("\t🌍  upstream changed (" + shortCommit(entry.Commit) + " → " + shortCommit(upstream) + ")")
+		}
+	}
+}
@@ -12,6 +12,7 @@ type DockerConfig struct {
 	Volumes     []VolumeMapping
 	Environment []EnvironmentPair
 	Image       string
+	Links       []string
 	Start       int
 	Stop        int
 }
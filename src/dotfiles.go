@@ -0,0 +1,117 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+)
+
+//Force overwrite existing files which aren't managed by sane
+var Force = false
+
+//dotfilesRoot the folder of the repo which is mirrored into the target (home by default)
+func dotfilesRoot(m map[string]interface{}) string {
+	if root, ok := m["root"]; ok {
+		return root.(string)
+	}
+
+	return "home"
+}
+
+//dotfilesConfig translate a dotfiles sanefile into a file config which mirrors the root folder into the target
+func dotfilesConfig(m map[string]interface{}) map[string]interface{} {
+	target := "$HOME"
+	if t, ok := m["target"]; ok {
+		target = t.(string)
+	}
+
+	strategy := SymlinkStrategy
+	if s, ok := m["strategy"]; ok {
+		strategy = s.(string)
+	}
+
+	ignore := make([]interface{}, 0)
+	if i, ok := m["ignore"]; ok {
+		ignore = i.([]interface{})
+	}
+
+	config := map[string]interface{}{
+		"mode":     "config",
+		"strategy": strategy,
+		"files": []interface{}{
+			map[interface{}]interface{}{
+				"file":       dotfilesRoot(m),
+				runtime.GOOS: target,
+				"exclude":    ignore,
+			},
+		},
+	}
+
+	if vars, ok := m["variables"]; ok {
+		config["variables"] = vars
+	}
+
+	return config
+}
+
+//findConflicts get the destinations which exist but were neither applied by sane nor are up to date
+func findConflicts(files []FileEntry, folder string, previous []LedgerFile) []string {
+	conflicts := make([]string, 0)
+
+	for _, f := range files {
+		if _, managed := findLedgerFile(previous, f.Destination); managed {
+			continue
+		}
+
+		if _, err := os.Lstat(f.Destination); os.IsNotExist(err) {
+			continue
+		}
+
+		target := path.Join(folder, f.Source)
+
+		switch f.Strategy {
+		case SymlinkStrategy:
+			if link, err := os.Readlink(f.Destination); err == nil && link == target {
+				continue
+			}
+		case HardlinkStrategy:
+			if SameFile(target, f.Destination) {
+				continue
+			}
+		default:
+			if !IsSymlink(f.Destination) && SameContent(target, f.Destination) {
+				continue
+			}
+		}
+
+		conflicts = append(conflicts, f.Destination)
+	}
+
+	return conflicts
+}
+
+func applyDotfiles(m map[string]interface{}, repo Repo, home string, cfg SaneConfig, entry LedgerEntry, tx *transaction) (LedgerEntry, []BackupFile) {
+	folder := path.Join(home, GetRepoFolder(repo))
+	config := dotfilesConfig(m)
+	root := dotfilesRoot(m)
+
+	if info, err := os.Stat(filepath.Join(folder, root)); err != nil || !info.IsDir() {
+		fmt.Println("❌  Dotfiles folder " + root + " not found!")
+		os.Exit(1)
+	}
+
+	files := extractFileConfig(config, folder, templateData(config, cfg))
+
+	if conflicts := findConflicts(files, folder, entry.Files); len(conflicts) != 0 && !Force {
+		fmt.Println("❌  These files already exist and aren't managed by sane:")
+		for _, conflict := range conflicts {
+			fmt.Println("\t" + conflict)
+		}
+		fmt.Println("Move them out of the way or apply with --force to back them up and replace them.")
+		os.Exit(1)
+	}
+
+	return applyConfig(config, repo, home, cfg, entry, tx)
+}
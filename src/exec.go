@@ -0,0 +1,96 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+//shellCmd starts bash if the container has it, sh otherwise
+var shellCmd = []string{"sh", "-c", "if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func getContainers(repo Repo, home string) []DockerConfig {
+	if instance, ok := ReadState().Instances[GetRepoString(repo)]; ok {
+		return instance.Containers
+	}
+
+	m := readSaneYml(repo, home)
+
+	if mode, ok := m["mode"]; !ok || mode.(string) != "docker" {
+		fmt.Println("❌  " + GetRepoString(repo) + " doesn't define any containers!")
+		os.Exit(1)
+	}
+
+	configs := extractDockerConfig(m)
+
+	sort.SliceStable(configs, func(i, j int) bool {
+		return configs[i].Start < configs[j].Start
+	})
+
+	return configs
+}
+
+//resolveContainer get the container of a config by name. Defaults to the first container if name is empty.
+func resolveContainer(repo Repo, home string, name string) DockerConfig {
+	containers := getContainers(repo, home)
+
+	if len(containers) == 0 {
+		fmt.Println("❌  " + GetRepoString(repo) + " doesn't define any containers!")
+		os.Exit(1)
+	}
+
+	if name == "" {
+		return containers[0]
+	}
+
+	names := make([]string, 0)
+	for _, c := range containers {
+		if c.Name == name {
+			return c
+		}
+
+		names = append(names, c.Name)
+	}
+
+	fmt.Println("❌  Container '" + name + "' not found! Available containers: " + strings.Join(names, ", "))
+	os.Exit(1)
+	return DockerConfig{}
+}
+
+//ExecContainer run a command in a container of a running config
+func ExecContainer(repo Repo, home string, container string, command []string) {
+	dockerConfig := resolveContainer(repo, home, container)
+
+	cmd := exec.Command("docker", "exec", "-i")
+
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		cmd.Args = append(cmd.Args, "-t")
+	}
+
+	cmd.Args = append(cmd.Args, dockerConfig.Name)
+	cmd.Args = append(cmd.Args, command...)
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		os.Exit(exitErr.ExitCode())
+	}
+
+	CheckWithMessage(err, "❌  There was an error while executing the command in '"+dockerConfig.Name+"'!")
+}
+
+//ShellContainer open an interactive shell in a container of a running config
+func ShellContainer(repo Repo, home string, container string) {
+	ExecContainer(repo, home, container, shellCmd)
+}
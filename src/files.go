@@ -0,0 +1,627 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const (
+	//CopyStrategy copy a file to its destination
+	CopyStrategy = "copy"
+	//SymlinkStrategy link the destination to the file in the repo folder
+	SymlinkStrategy = "symlink"
+	//HardlinkStrategy hardlink the destination to the file in the repo folder
+	HardlinkStrategy = "hardlink"
+	//MergeStrategy deep merge the file into the destination (json, yaml, toml or ini)
+	MergeStrategy = "merge"
+	//BlockStrategy put the file between markers into the destination, leaving the rest of it alone
+	BlockStrategy = "block"
+)
+
+var strategies = []string{CopyStrategy, SymlinkStrategy, HardlinkStrategy, MergeStrategy, BlockStrategy}
+
+//FileEntry a file of a config and its destination
+type FileEntry struct {
+	Source      string
+	Destination string
+	Strategy    string
+	Template    bool
+	Format      string
+	Comment     string
+	Mode        os.FileMode
+	UID         int
+	GID         int
+	Privileged  bool
+}
+
+func extractStrategy(m map[interface{}]interface{}, def string) string {
+	if strategy, ok := m["strategy"]; ok {
+		if !ContainsString(strategies, strategy.(string)) {
+			CheckCouldntParse(errors.New(""), "Unsupported strategy \""+strategy.(string)+"\"!")
+		}
+
+		return strategy.(string)
+	}
+
+	return def
+}
+
+func extractPatterns(m map[interface{}]interface{}, key string) []string {
+	patterns := make([]string, 0)
+
+	if list, ok := m[key]; ok {
+		for _, p := range list.([]interface{}) {
+			if _, err := filepath.Match(p.(string), ""); err != nil {
+				CheckCouldntParse(err, "Invalid pattern \""+p.(string)+"\"!")
+			}
+
+			patterns = append(patterns, p.(string))
+		}
+	}
+
+	return patterns
+}
+
+//matchesAny check if a relative path or its base name matches any of the patterns
+func matchesAny(rel string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, filepath.ToSlash(rel)); ok {
+			return true
+		}
+
+		if ok, _ := filepath.Match(p, filepath.Base(rel)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+//expandDirectory map every file below a directory of the repo folder into a destination directory
+func expandDirectory(folder string, entry FileEntry, include, exclude []string) []FileEntry {
+	entries := make([]FileEntry, 0)
+	root := filepath.Join(folder, entry.Source)
+
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+
+		if (len(include) != 0 && !matchesAny(rel, include)) || matchesAny(rel, exclude) {
+			return nil
+		}
+
+		e := entry
+		e.Source = filepath.Join(entry.Source, rel)
+		e.Destination = filepath.Join(entry.Destination, rel)
+
+		entries = append(entries, e)
+		return nil
+	})
+	CheckWithMessage(err, "❌  There was an error while reading "+root+"!")
+
+	return entries
+}
+
+//expandFileEntry expand directory and glob sources into one entry per file
+func expandFileEntry(folder string, entry FileEntry, include, exclude []string) []FileEntry {
+	if strings.ContainsAny(entry.Source, "*?[") {
+		matches, err := filepath.Glob(filepath.Join(folder, entry.Source))
+		CheckCouldntParse(err, "Invalid pattern \""+entry.Source+"\"!")
+
+		if len(matches) == 0 {
+			fmt.Println("❌  No files match " + entry.Source + "!")
+			os.Exit(1)
+		}
+
+		entries := make([]FileEntry, 0)
+		for _, match := range matches {
+			rel, err := filepath.Rel(folder, match)
+			Check(err)
+
+			// glob matches are mapped into the destination directory
+			e := entry
+			e.Source = rel
+			e.Destination = filepath.Join(entry.Destination, filepath.Base(match))
+
+			entries = append(entries, expandFileEntry(folder, e, include, exclude)...)
+		}
+
+		return entries
+	}
+
+	if info, err := os.Stat(filepath.Join(folder, entry.Source)); err == nil && info.IsDir() {
+		return expandDirectory(folder, entry, include, exclude)
+	}
+
+	return []FileEntry{entry}
+}
+
+//skipEntry report a file entry which doesn't apply to this machine
+func skipEntry(source string, reason string) {
+	if DryRun {
+		plan("skip", source+" ("+reason+")")
+		return
+	}
+
+	fmt.Println("⏭  Skipping " + source + " (" + reason + ")")
+}
+
+func extractFileConfig(m map[string]interface{}, folder string, data TemplateData) []FileEntry {
+	entries := make([]FileEntry, 0)
+
+	strategy := CopyStrategy
+	if s, ok := m["strategy"]; ok {
+		strategy = extractStrategy(map[interface{}]interface{}{"strategy": s}, CopyStrategy)
+	}
+
+	if files, ok := m["files"]; ok {
+		for _, v := range files.([]interface{}) {
+			m := v.(map[interface{}]interface{})
+			source := m["file"].(string)
+
+			if when, ok := m["when"]; ok {
+				match, err := evalCondition(fmt.Sprintf("%v", when), data)
+				if err != nil {
+					CheckCouldntParse(err, "Invalid condition of "+source+": "+err.Error())
+				}
+
+				if !match {
+					skipEntry(source, "condition doesn't match")
+					continue
+				}
+			}
+
+			destination, ok := m[runtime.GOOS].(string)
+			if !ok {
+				skipEntry(source, "no destination for "+runtime.GOOS)
+				continue
+			}
+
+			entry := FileEntry{
+				Source:      source,
+				Destination: os.ExpandEnv(destination),
+				Strategy:    extractStrategy(m, strategy),
+				UID:         -1,
+				GID:         -1,
+			}
+
+			if template, ok := m["template"]; ok {
+				entry.Template = template.(bool)
+			}
+
+			if entry.Template && (entry.Strategy == SymlinkStrategy || entry.Strategy == HardlinkStrategy) {
+				CheckCouldntParse(errors.New(""), "Template "+entry.Source+" can't be linked!")
+			}
+
+			if format, ok := m["format"]; ok {
+				entry.Format = format.(string)
+			}
+
+			if priv, ok := m["privileged"]; ok {
+				entry.Privileged = priv.(bool)
+			}
+
+			if mode, ok := m["mode"]; ok {
+				fileMode, err := parseMode(mode)
+				if err != nil {
+					CheckCouldntParse(err, err.Error())
+				}
+
+				entry.Mode = fileMode
+			}
+
+			owner, _ := m["owner"].(string)
+			group, _ := m["group"].(string)
+
+			uid, gid, err := lookupOwner(owner, group)
+			if err != nil {
+				CheckCouldntParse(err, err.Error())
+			}
+
+			entry.UID, entry.GID = uid, gid
+
+			if (entry.Mode != 0 || uid >= 0 || gid >= 0) && (entry.Strategy == SymlinkStrategy || entry.Strategy == HardlinkStrategy) {
+				CheckCouldntParse(errors.New(""), "Permissions of "+entry.Source+" can't be set because it's linked!")
+			}
+
+			if entry.Strategy == BlockStrategy {
+				entry.Comment = DefaultComment
+				if comment, ok := m["comment"]; ok {
+					entry.Comment = comment.(string)
+				}
+			}
+
+			entries = append(entries, expandFileEntry(folder, entry, extractPatterns(m, "include"), extractPatterns(m, "exclude"))...)
+		}
+	} else {
+		fmt.Println("❌  Files not found!")
+		os.Exit(1)
+	}
+
+	for i, entry := range entries {
+		if entry.Strategy == MergeStrategy && entry.Format == "" {
+			format, err := detectMergeFormat(entry.Destination)
+			if err != nil {
+				CheckCouldntParse(err, err.Error())
+			}
+
+			entries[i].Format = format
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Destination < entries[j].Destination
+	})
+
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Destination == entries[i-1].Destination {
+			CheckCouldntParse(errors.New(""), entries[i].Destination+" is the destination of more than one file!")
+		}
+	}
+
+	return entries
+}
+
+//renderTemplates render every templated file. Returns the content by destination.
+func renderTemplates(files []FileEntry, folder string, data TemplateData) map[string][]byte {
+	rendered := make(map[string][]byte)
+
+	for _, f := range files {
+		if f.Template {
+			content, err := renderTemplate(path.Join(folder, f.Source), data)
+			if err != nil {
+				fmt.Println("❌  Couldn't render template " + f.Source + ": " + err.Error())
+				os.Exit(1)
+			}
+
+			rendered[f.Destination] = content
+		}
+	}
+
+	return rendered
+}
+
+//legacyBackup whether <dst>.backup was left by a version of sane without a ledger. Those versions renamed the original
+//to <dst>.backup and copied the file of the repo in its place, so the destination still has to be that copy.
+func legacyBackup(target string, dst string) bool {
+	if _, err := os.Stat(dst + ".backup"); err != nil {
+		return false
+	}
+
+	return !IsSymlink(dst) && SameContent(target, dst)
+}
+
+//migrateLegacyBackup move a <dst>.backup of an older version of sane into the backup store as the original
+func migrateLegacyBackup(tx *transaction, record *LedgerFile) {
+	legacy := record.Destination + ".backup"
+
+	hash, err := storeBackup(legacy)
+	tx.check(err, "❌  There was an error while backing up "+legacy+"!")
+
+	meta, err := readMeta(legacy)
+	tx.check(err, "❌  There was an error while reading "+legacy+"!")
+
+	record.Backup = hash
+	record.Original = meta
+
+	err = tx.delete(legacy)
+	tx.check(err, "❌  There was an error while deleting "+legacy+"!")
+}
+
+//applyConfig apply the files of a config. Returns the ledger entry and the backups of the previous state.
+func applyConfig(m map[string]interface{}, repo Repo, home string, cfg SaneConfig, entry LedgerEntry, tx *transaction) (LedgerEntry, []BackupFile) {
+	previous := entry.Files
+	entry.Files = make([]LedgerFile, 0)
+
+	data := templateData(m, cfg)
+	files := extractFileConfig(m, path.Join(home, GetRepoFolder(repo)), data)
+	rendered := renderTemplates(files, path.Join(home, GetRepoFolder(repo)), data)
+
+	legacy := make(map[string]bool)
+
+	// stage every file before touching anything
+	for _, f := range files {
+		target := path.Join(home, GetRepoFolder(repo), f.Source)
+
+		if len(previous) == 0 && legacyBackup(target, f.Destination) {
+			legacy[f.Destination] = true
+		}
+
+		hash, err := FileHash(target)
+		CheckWithMessage(err, "❌  There was an error while reading "+target+"!")
+
+		if content, ok := rendered[f.Destination]; ok {
+			hash = ContentHash(content)
+		}
+
+		record, managed := findLedgerFile(previous, f.Destination)
+		if managed {
+			record.Backup = refreshBackup(repo, record)
+		}
+
+		record.Source = f.Source
+		record.Destination = f.Destination
+		record.Strategy = f.Strategy
+		record.Template = f.Template
+		record.Format = f.Format
+		record.Comment = f.Comment
+		record.Privileged = f.Privileged
+
+		if f.Privileged {
+			markPrivileged(f.Destination)
+		}
+		record.Hash = hash
+
+		if f.Strategy == MergeStrategy {
+			content, ok := rendered[f.Destination]
+			if !ok {
+				content, _ = ioutil.ReadFile(target)
+			}
+
+			// a missing destination is merged like an empty one
+			existing, _ := readDestination(f.Destination)
+
+			merged, changes, err := mergeContent(existing, content, f.Format, record.Changes)
+			if err != nil {
+				fmt.Println("❌  Couldn't merge " + f.Source + " into " + f.Destination + ": " + err.Error())
+				os.Exit(1)
+			}
+
+			rendered[f.Destination] = merged
+			record.Changes = changes
+			record.Hash = ContentHash(merged)
+		}
+
+		if f.Strategy == BlockStrategy {
+			content, ok := rendered[f.Destination]
+			if !ok {
+				content, _ = ioutil.ReadFile(target)
+			}
+
+			existing, _ := readDestination(f.Destination)
+
+			block := insertBlock(existing, content, GetRepoString(repo), f.Comment)
+			rendered[f.Destination] = block
+			record.Hash = ContentHash(block)
+		}
+
+		entry.Files = append(entry.Files, record)
+	}
+
+	return installFiles(repo, entry, previous, tx, func(i int, record *LedgerFile) {
+		target := path.Join(home, GetRepoFolder(repo), record.Source)
+		dst := record.Destination
+
+		if legacy[dst] {
+			migrateLegacyBackup(tx, record)
+		}
+
+		switch record.Strategy {
+		case SymlinkStrategy:
+			if link, err := os.Readlink(dst); err == nil && link == target {
+				fmt.Println("👌  " + dst + " is up to date")
+				return
+			}
+
+			err := tx.symlink(target, dst)
+			tx.check(err, "❌  There was an error while linking "+dst+" to "+target+"!")
+			return
+		case HardlinkStrategy:
+			if SameFile(target, dst) {
+				fmt.Println("👌  " + dst + " is up to date")
+				return
+			}
+
+			err := tx.hardlink(target, dst)
+			tx.check(err, "❌  There was an error while linking "+dst+" to "+target+"!")
+			return
+		}
+
+		if content, ok := rendered[dst]; ok {
+			if !IsSymlink(dst) && HasContent(dst, content) {
+				fmt.Println("👌  " + dst + " is up to date")
+			} else {
+				err := tx.write(dst, content)
+				tx.check(err, "❌  There was an error while writing "+dst+"!")
+			}
+		} else if !IsSymlink(dst) && SameContent(target, dst) {
+			fmt.Println("👌  " + dst + " is up to date")
+		} else {
+			err := tx.copy(target, dst)
+			tx.check(err, "❌  There was an error while copying "+target+" to "+dst+"!")
+		}
+
+		if f := files[i]; f.Mode != 0 {
+			err := tx.chmod(dst, f.Mode)
+			tx.check(err, "❌  There was an error while setting the permissions of "+dst+"!")
+		}
+
+		err := tx.chown(dst, files[i].UID, files[i].GID)
+		tx.check(err, "❌  There was an error while setting the owner of "+dst+"!")
+	})
+}
+
+//installFiles back up the destinations of the staged ledger files and of the ones dropped since the last apply, then
+//install every file. Dropped files are restored. Returns the ledger entry and the backups of the previous state.
+func installFiles(repo Repo, entry LedgerEntry, previous []LedgerFile, tx *transaction, install func(i int, record *LedgerFile)) (LedgerEntry, []BackupFile) {
+	dsts := make([]string, 0)
+	for _, f := range entry.Files {
+		dsts = append(dsts, f.Destination)
+	}
+
+	for _, f := range previous {
+		if _, ok := findLedgerFile(entry.Files, f.Destination); !ok {
+			dsts = append(dsts, f.Destination)
+
+			if f.Privileged {
+				markPrivileged(f.Destination)
+			}
+		}
+	}
+
+	backups := snapshot(dsts)
+
+	for i := range entry.Files {
+		record := &entry.Files[i]
+		dst := record.Destination
+
+		if _, managed := findLedgerFile(previous, dst); !managed {
+			// the original stays in the backup store, an empty hash means there was none
+			record.Backup = backups[i].Hash
+			record.Original = backups[i].Meta
+
+			err := tx.makeDir(filepath.Dir(dst))
+			tx.check(err, "❌  There was an error while creating a directory!")
+		}
+
+		install(i, record)
+	}
+
+	// files which were dropped from the sanefile since the last apply
+	for _, f := range previous {
+		if _, ok := findLedgerFile(entry.Files, f.Destination); !ok {
+			restoreFile(tx, repo, f)
+		}
+	}
+
+	for _, dir := range tx.created {
+		if !ContainsString(entry.Directories, dir) {
+			entry.Directories = append(entry.Directories, dir)
+		}
+	}
+
+	return entry, backups
+}
+
+//stripManaged remove what sane added to a merged or managed block destination. Returns whether anything is left.
+func stripManaged(repo Repo, f LedgerFile, existing []byte) ([]byte, bool, error) {
+	if f.Strategy == BlockStrategy {
+		b, left := removeBlock(existing, GetRepoString(repo), f.Comment)
+		return b, left, nil
+	}
+
+	return unmergeContent(existing, f.Format, f.Changes)
+}
+
+//refreshBackup keep the user's edits to a merged or managed block destination since the last apply as what remove goes back to
+func refreshBackup(repo Repo, f LedgerFile) string {
+	if f.Strategy != MergeStrategy && f.Strategy != BlockStrategy {
+		return f.Backup
+	}
+
+	existing, err := readDestination(f.Destination)
+	if err != nil || ContentHash(existing) == f.Hash {
+		return f.Backup
+	}
+
+	b, left, err := stripManaged(repo, f, existing)
+	if err != nil || (!left && f.Backup == "") {
+		return f.Backup
+	}
+
+	hash, err := storeContent(b)
+	CheckWithMessage(err, "❌  There was an error while backing up "+f.Destination+"!")
+
+	return hash
+}
+
+func restoreFile(tx *transaction, repo Repo, f LedgerFile) {
+	if f.Strategy == MergeStrategy || f.Strategy == BlockStrategy {
+		existing, err := readDestination(f.Destination)
+		if os.IsNotExist(err) {
+			return
+		}
+		tx.check(err, "❌  There was an error while reading "+f.Destination+"!")
+
+		// untouched since the apply, the backup is exactly what was there before
+		if ContentHash(existing) == f.Hash {
+			restoreFromBackup(tx, f.Destination, f.Backup, f.Original)
+			return
+		}
+
+		b, left, err := stripManaged(repo, f, existing)
+		if err != nil {
+			tx.check(err, "❌  Couldn't unmerge "+f.Destination+": "+err.Error())
+		}
+
+		// delete files which only contain what sane added
+		if !left && f.Backup == "" {
+			err = tx.delete(f.Destination)
+		} else {
+			err = tx.write(f.Destination, b)
+		}
+		tx.check(err, "❌  There was an error while restoring "+f.Destination+"!")
+
+		if left || f.Backup != "" {
+			restoreMeta(tx, f.Destination, f.Original)
+		}
+		return
+	}
+
+	if f.Strategy == SymlinkStrategy {
+		link, err := os.Readlink(f.Destination)
+		_, statErr := os.Lstat(f.Destination)
+
+		// only remove links which still point into sane's folder
+		if !os.IsNotExist(statErr) && (err != nil || !strings.HasPrefix(link, GetSaneFile("")+string(filepath.Separator))) {
+			fmt.Println("⚠️  " + f.Destination + " doesn't link into sane's folder anymore, leaving it in place")
+			return
+		}
+	} else if hash, err := FileHash(f.Destination); err == nil && hash != f.Hash {
+		fmt.Println("⚠️  " + f.Destination + " was modified since it was applied")
+	}
+
+	if filepath.IsAbs(f.Backup) {
+		// applied by a version of sane without a ledger which kept the backup next to the destination
+		legacy := f.Backup
+
+		hash, err := storeBackup(legacy)
+		tx.check(err, "❌  There was an error while backing up "+legacy+"!")
+		f.Backup = hash
+
+		err = tx.delete(legacy)
+		tx.check(err, "❌  There was an error while deleting "+legacy+"!")
+	}
+
+	restoreFromBackup(tx, f.Destination, f.Backup, f.Original)
+}
+
+//removeConfig restore the files of an applied config. Returns the backups of the previous state.
+func removeConfig(entry LedgerEntry, tx *transaction) []BackupFile {
+	dsts := make([]string, 0)
+	for _, f := range entry.Files {
+		dsts = append(dsts, f.Destination)
+
+		if f.Privileged {
+			markPrivileged(f.Destination)
+		}
+	}
+
+	backups := snapshot(dsts)
+
+	for _, f := range entry.Files {
+		restoreFile(tx, entry.Repo, f)
+	}
+
+	return backups
+}
@@ -0,0 +1,229 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hacdias/fileutils"
+)
+
+//Elevation the command privileged files are changed with (sudo, doas, ...)
+var Elevation = "sudo"
+
+//fileSystem the operations sane performs on destinations
+type fileSystem interface {
+	ReadFile(file string) ([]byte, error)
+	WriteFile(file string, content []byte, perm os.FileMode) error
+	Remove(file string) error
+	MkdirAll(dir string, perm os.FileMode) error
+	Chmod(file string, mode os.FileMode) error
+	Chown(file string, uid int, gid int) error
+	Symlink(target string, file string) error
+	Link(target string, file string) error
+	Copy(src string, dst string) error
+}
+
+//privileged destinations which are changed through the elevation command
+var privileged = make(map[string]bool)
+
+func markPrivileged(file string) {
+	privileged[filepath.Clean(file)] = true
+}
+
+//isPrivileged check if a file is a privileged destination or a directory containing one
+func isPrivileged(file string) bool {
+	file = filepath.Clean(file)
+
+	for p := range privileged {
+		if p == file || strings.HasPrefix(p, file+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//fsFor get the file system a file is changed through. sane itself never runs as root.
+func fsFor(file string) fileSystem {
+	// root doesn't need to elevate, windows doesn't support it
+	if os.Geteuid() <= 0 || !isPrivileged(file) {
+		return localFS{}
+	}
+
+	return elevatedFS{}
+}
+
+//readDestination read a file, privileged files which aren't readable are read through the elevation command
+func readDestination(file string) ([]byte, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsPermission(err) {
+		return fsFor(file).ReadFile(file)
+	}
+
+	return b, err
+}
+
+type localFS struct{}
+
+func (localFS) ReadFile(file string) ([]byte, error) {
+	return ioutil.ReadFile(file)
+}
+
+func (localFS) WriteFile(file string, content []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(file, content, perm)
+}
+
+func (localFS) Remove(file string) error {
+	return os.Remove(file)
+}
+
+func (localFS) MkdirAll(dir string, perm os.FileMode) error {
+	return os.MkdirAll(dir, perm)
+}
+
+func (localFS) Chmod(file string, mode os.FileMode) error {
+	return os.Chmod(file, mode)
+}
+
+func (localFS) Chown(file string, uid int, gid int) error {
+	return setOwner(file, uid, gid)
+}
+
+func (localFS) Symlink(target string, file string) error {
+	return os.Symlink(target, file)
+}
+
+func (localFS) Link(target string, file string) error {
+	return os.Link(target, file)
+}
+
+func (localFS) Copy(src string, dst string) error {
+	return fileutils.CopyFile(src, dst)
+}
+
+//elevatedFS runs every operation through the elevation command
+type elevatedFS struct{}
+
+var authenticated = false
+
+//authenticate ask for the password once per run, the elevation command caches it
+func authenticate() error {
+	if authenticated {
+		return nil
+	}
+
+	fields := strings.Fields(Elevation)
+	if len(fields) == 0 {
+		return errors.New("no elevation command configured")
+	}
+
+	fmt.Println("🔐  Privileged files are changed with " + fields[0])
+
+	cmd := exec.Command(fields[0], append(fields[1:], "true")...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return errors.New("couldn't elevate with " + Elevation)
+	}
+
+	authenticated = true
+	return nil
+}
+
+func (elevatedFS) run(stdin []byte, args ...string) ([]byte, error) {
+	if err := authenticate(); err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(Elevation)
+	cmd := exec.Command(fields[0], append(fields[1:], args...)...)
+
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil && stderr.Len() != 0 {
+		return nil, errors.New(strings.TrimSpace(stderr.String()))
+	}
+
+	return out, err
+}
+
+func (e elevatedFS) ReadFile(file string) ([]byte, error) {
+	return e.run(nil, "cat", file)
+}
+
+func (e elevatedFS) WriteFile(file string, content []byte, perm os.FileMode) error {
+	_, statErr := os.Lstat(file)
+
+	if _, err := e.run(content, "tee", file); err != nil {
+		return err
+	}
+
+	// like ioutil.WriteFile perm only applies to new files
+	if os.IsNotExist(statErr) {
+		return e.Chmod(file, perm)
+	}
+
+	return nil
+}
+
+func (e elevatedFS) Remove(file string) error {
+	if _, err := os.Lstat(file); err != nil {
+		return err
+	}
+
+	_, err := e.run(nil, "rm", "-d", file)
+	return err
+}
+
+func (e elevatedFS) MkdirAll(dir string, perm os.FileMode) error {
+	_, err := e.run(nil, "mkdir", "-p", "-m", fmt.Sprintf("%04o", perm), dir)
+	return err
+}
+
+func (e elevatedFS) Chmod(file string, mode os.FileMode) error {
+	_, err := e.run(nil, "chmod", fmt.Sprintf("%04o", mode), file)
+	return err
+}
+
+func (e elevatedFS) Chown(file string, uid int, gid int) error {
+	owner := ""
+	if uid >= 0 {
+		owner = strconv.Itoa(uid)
+	}
+	if gid >= 0 {
+		owner += ":" + strconv.Itoa(gid)
+	}
+
+	_, err := e.run(nil, "chown", owner, file)
+	return err
+}
+
+func (e elevatedFS) Symlink(target string, file string) error {
+	_, err := e.run(nil, "ln", "-s", target, file)
+	return err
+}
+
+func (e elevatedFS) Link(target string, file string) error {
+	_, err := e.run(nil, "ln", target, file)
+	return err
+}
+
+func (e elevatedFS) Copy(src string, dst string) error {
+	_, err := e.run(nil, "cp", src, dst)
+	return err
+}
@@ -0,0 +1,164 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+const (
+	//PreApply run before a config is applied
+	PreApply = "pre_apply"
+	//PostApply run after a config was applied, a failure rolls the apply back
+	PostApply = "post_apply"
+	//PreRemove run before a config is removed
+	PreRemove = "pre_remove"
+	//PostRemove run after a config was removed, a failure rolls the remove back
+	PostRemove = "post_remove"
+	//PreStart run before the containers of a config are started
+	PreStart = "pre_start"
+	//PostStart run after the containers of a config were started, a failure stops them again
+	PostStart = "post_start"
+	//PreStop run before the containers of a config are stopped
+	PreStop = "pre_stop"
+	//PostStop run after the containers of a config were stopped
+	PostStop = "post_stop"
+)
+
+var hookEvents = []string{PreApply, PostApply, PreRemove, PostRemove, PreStart, PostStart, PreStop, PostStop}
+
+//DefaultHookTimeout the time a hook may run if it doesn't set a timeout
+const DefaultHookTimeout = 5 * time.Minute
+
+//Hook a command run before or after an action of a config
+type Hook struct {
+	Command       string        `json:"command"`
+	Timeout       time.Duration `json:"timeout"`
+	IgnoreFailure bool          `json:"ignore_failure"`
+}
+
+func extractTimeout(v interface{}) time.Duration {
+	switch val := v.(type) {
+	case int:
+		// plain numbers are seconds
+		return time.Duration(val) * time.Second
+	case string:
+		timeout, err := time.ParseDuration(val)
+		if err != nil {
+			CheckCouldntParse(err, "Invalid timeout \""+val+"\"!")
+		}
+		return timeout
+	}
+
+	CheckCouldntParse(errors.New(""), fmt.Sprintf("Invalid timeout %v!", v))
+	return 0
+}
+
+//extractHooks read the hooks of a sanefile. A hook is a command or an object with command, timeout and ignore_failure.
+func extractHooks(m map[string]interface{}) map[string][]Hook {
+	hooks := make(map[string][]Hook)
+
+	v, ok := m["hooks"]
+	if !ok {
+		return hooks
+	}
+
+	for event, list := range v.(map[interface{}]interface{}) {
+		if !ContainsString(hookEvents, event.(string)) {
+			CheckCouldntParse(errors.New(""), "Unknown hook \""+event.(string)+"\"!")
+		}
+
+		for _, h := range list.([]interface{}) {
+			hook := Hook{Timeout: DefaultHookTimeout}
+
+			switch val := h.(type) {
+			case string:
+				hook.Command = val
+			case map[interface{}]interface{}:
+				hook.Command, _ = val["command"].(string)
+
+				if timeout, ok := val["timeout"]; ok {
+					hook.Timeout = extractTimeout(timeout)
+				}
+
+				if ignore, ok := val["ignore_failure"]; ok {
+					hook.IgnoreFailure = ignore.(bool)
+				}
+			}
+
+			if hook.Command == "" {
+				CheckCouldntParse(errors.New(""), "Hook without a command in "+event.(string)+"!")
+			}
+
+			hooks[event.(string)] = append(hooks[event.(string)], hook)
+		}
+	}
+
+	return hooks
+}
+
+func hookCmd(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+//runHooks run the hooks of an event from the repo folder. Returns the first failure that isn't ignored.
+func runHooks(hooks map[string][]Hook, event string, repo Repo) error {
+	folder := GetSaneFile(GetRepoFolder(repo))
+
+	for _, hook := range hooks[event] {
+		if DryRun {
+			plan("hook", event+": "+hook.Command)
+			continue
+		}
+
+		fmt.Println("🪝  Running " + event + " hook: " + hook.Command)
+
+		ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout)
+		cmd := hookCmd(ctx, hook.Command)
+		cmd.Dir = folder
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(),
+			"SANE_CONFIG="+GetRepoString(repo),
+			"SANE_REPO_DIR="+folder,
+			"SANE_HOOK="+event,
+			"SANE_HOME="+GetSaneFile(""),
+		)
+
+		err := cmd.Run()
+		if ctx.Err() == context.DeadlineExceeded {
+			err = errors.New("timed out after " + hook.Timeout.String())
+		}
+		cancel()
+
+		if err == nil {
+			continue
+		}
+
+		if hook.IgnoreFailure {
+			fmt.Println("⚠️  " + event + " hook \"" + hook.Command + "\" failed, ignoring it: " + err.Error())
+			continue
+		}
+
+		return errors.New(event + " hook \"" + hook.Command + "\" failed: " + err.Error())
+	}
+
+	return nil
+}
+
+//checkHooks run the hooks of an event and exit if one fails
+func checkHooks(hooks map[string][]Hook, event string, repo Repo) {
+	if err := runHooks(hooks, event, repo); err != nil {
+		fmt.Println("❌  " + err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,331 @@
+package src
+
+import (
+	"strings"
+)
+
+//iniLine a line of an ini file. Lines are kept as they are so comments, formatting and repeated keys survive a merge.
+type iniLine struct {
+	raw     string
+	section string
+	// empty for comments, blank lines and section headers
+	key    string
+	header bool
+}
+
+//iniDocument the lines of an ini file
+type iniDocument struct {
+	lines []iniLine
+	// whether the file ends with a newline
+	trailing bool
+}
+
+func parseINILine(raw string, section string) iniLine {
+	line := strings.TrimSpace(raw)
+
+	switch {
+	case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		return iniLine{raw: raw, section: section}
+	case strings.HasPrefix(line, "[") && strings.Contains(line, "]"):
+		name := strings.TrimSpace(line[1:strings.LastIndex(line, "]")])
+		return iniLine{raw: raw, section: name, header: true}
+	}
+
+	// keys without a value are booleans (git's "bare")
+	key := line
+	if i := strings.Index(line, "="); i >= 0 {
+		key = strings.TrimSpace(line[:i])
+	}
+
+	return iniLine{raw: raw, section: section, key: key}
+}
+
+func parseINI(b []byte) *iniDocument {
+	doc := &iniDocument{lines: make([]iniLine, 0)}
+
+	text := strings.ReplaceAll(string(b), "\r\n", "\n")
+	if strings.TrimSpace(text) == "" {
+		doc.trailing = true
+		return doc
+	}
+
+	if strings.HasSuffix(text, "\n") {
+		doc.trailing = true
+		text = strings.TrimSuffix(text, "\n")
+	}
+
+	section := ""
+	for _, raw := range strings.Split(text, "\n") {
+		line := parseINILine(raw, section)
+		section = line.section
+		doc.lines = append(doc.lines, line)
+	}
+
+	return doc
+}
+
+func (doc *iniDocument) bytes() []byte {
+	raw := make([]string, 0, len(doc.lines))
+	for _, line := range doc.lines {
+		raw = append(raw, line.raw)
+	}
+
+	text := strings.Join(raw, "\n")
+	if len(raw) != 0 && doc.trailing {
+		text += "\n"
+	}
+
+	return []byte(text)
+}
+
+//value a key line without its formatting, used to compare lines
+func (line iniLine) value() string {
+	text := strings.TrimSpace(line.raw)
+
+	if i := strings.Index(text, "="); i >= 0 {
+		return line.key + "=" + strings.TrimSpace(text[i+1:])
+	}
+
+	return line.key
+}
+
+func (doc *iniDocument) hasSection(section string) bool {
+	if section == "" {
+		return true
+	}
+
+	for _, line := range doc.lines {
+		if line.header && line.section == section {
+			return true
+		}
+	}
+
+	return false
+}
+
+//find the indexes of the lines of a key, a repeated key has several
+func (doc *iniDocument) find(section string, key string) []int {
+	indexes := make([]int, 0)
+
+	for i, line := range doc.lines {
+		if !line.header && line.key == key && line.section == section {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes
+}
+
+//sectionEnd the index after the last header or key of a section, trailing comments and blank lines belong to the next one
+func (doc *iniDocument) sectionEnd(section string) int {
+	end := -1
+
+	for i, line := range doc.lines {
+		if line.section == section && (line.header || line.key != "") {
+			end = i + 1
+		}
+	}
+
+	if end < 0 && section == "" {
+		// keys outside of a section go before the first header
+		for i, line := range doc.lines {
+			if line.header {
+				return i
+			}
+		}
+
+		return len(doc.lines)
+	}
+
+	return end
+}
+
+func (doc *iniDocument) insert(i int, lines []iniLine) {
+	doc.lines = append(doc.lines[:i], append(append([]iniLine{}, lines...), doc.lines[i:]...)...)
+}
+
+//set replace the lines of a key by the given ones. A key which doesn't exist is added to the end of its section.
+func (doc *iniDocument) set(section string, key string, lines []iniLine) {
+	indexes := doc.find(section, key)
+	if len(indexes) == 0 && len(lines) == 0 {
+		return
+	}
+
+	at := doc.sectionEnd(section)
+	if len(indexes) != 0 {
+		at = indexes[0]
+	}
+
+	for i := len(indexes) - 1; i >= 0; i-- {
+		doc.lines = append(doc.lines[:indexes[i]], doc.lines[indexes[i]+1:]...)
+	}
+
+	doc.insert(at, lines)
+}
+
+func (doc *iniDocument) addSection(section string) {
+	if n := len(doc.lines); n != 0 && strings.TrimSpace(doc.lines[n-1].raw) != "" {
+		doc.lines = append(doc.lines, iniLine{section: doc.lines[n-1].section})
+	}
+
+	doc.lines = append(doc.lines, iniLine{raw: "[" + section + "]", section: section, header: true})
+}
+
+//removeSection remove a section sane added if nothing but comments is left in it
+func (doc *iniDocument) removeSection(section string) {
+	lines := make([]iniLine, 0, len(doc.lines))
+
+	for _, line := range doc.lines {
+		if line.section == section && !line.header && line.key != "" {
+			return
+		}
+
+		if line.section != section {
+			lines = append(lines, line)
+		}
+	}
+
+	// the blank line sane put in front of the section
+	if n := len(lines); n != 0 && strings.TrimSpace(lines[n-1].raw) == "" {
+		lines = lines[:n-1]
+	}
+
+	doc.lines = lines
+}
+
+func (doc *iniDocument) empty() bool {
+	for _, line := range doc.lines {
+		if strings.TrimSpace(line.raw) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func rawLines(doc *iniDocument, indexes []int) []string {
+	raw := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		raw = append(raw, doc.lines[i].raw)
+	}
+
+	return raw
+}
+
+//unmergeINI put back the lines the recorded changes replaced and remove the ones sane added
+func unmergeINI(doc *iniDocument, changes []MergeChange) error {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		section := change.Path[0]
+
+		if len(change.Path) == 1 {
+			if !change.Existed {
+				doc.removeSection(section)
+			}
+			continue
+		}
+
+		key := change.Path[1]
+
+		if !change.Existed {
+			doc.set(section, key, nil)
+			continue
+		}
+
+		if !doc.hasSection(section) {
+			// removed by the user in the meantime
+			continue
+		}
+
+		old := strings.Split(change.Old, "\n")
+
+		lines := make([]iniLine, 0, len(old))
+		for _, raw := range old {
+			lines = append(lines, parseINILine(raw, section))
+		}
+
+		doc.set(section, key, lines)
+	}
+
+	return nil
+}
+
+//mergeINI merge the keys of content into an ini file line by line. Keys sane sets replace every line of that key.
+func mergeINI(existing []byte, content []byte, previous []MergeChange) ([]byte, []MergeChange, error) {
+	doc := parseINI(existing)
+	if err := unmergeINI(doc, previous); err != nil {
+		return nil, nil, err
+	}
+
+	src := parseINI(content)
+	changes := make([]MergeChange, 0)
+
+	sections := make([]string, 0)
+	keys := make(map[string][]string)
+	values := make(map[string]map[string][]iniLine)
+
+	for _, line := range src.lines {
+		if _, ok := values[line.section]; !ok && (line.header || line.key != "") {
+			sections = append(sections, line.section)
+			values[line.section] = make(map[string][]iniLine)
+		}
+
+		if line.header || line.key == "" {
+			continue
+		}
+
+		if _, ok := values[line.section][line.key]; !ok {
+			keys[line.section] = append(keys[line.section], line.key)
+		}
+
+		raw := strings.TrimSpace(line.raw)
+		if line.section != "" {
+			raw = "\t" + raw
+		}
+
+		values[line.section][line.key] = append(values[line.section][line.key], iniLine{raw: raw, section: line.section, key: line.key})
+	}
+
+	for _, section := range sections {
+		if !doc.hasSection(section) {
+			doc.addSection(section)
+			changes = append(changes, MergeChange{Path: []string{section}})
+		}
+
+		for _, key := range keys[section] {
+			lines := values[section][key]
+			indexes := doc.find(section, key)
+
+			if len(indexes) == len(lines) {
+				same := true
+				for i, index := range indexes {
+					same = same && doc.lines[index].value() == lines[i].value()
+				}
+
+				if same {
+					continue
+				}
+			}
+
+			change := MergeChange{Path: []string{section, key}, Existed: len(indexes) != 0}
+			if change.Existed {
+				change.Old = strings.Join(rawLines(doc, indexes), "\n")
+			}
+
+			changes = append(changes, change)
+			doc.set(section, key, lines)
+		}
+	}
+
+	return doc.bytes(), changes, nil
+}
+
+//unmergeINIContent remove the changes sane made from a merged ini file. Returns whether anything is left.
+func unmergeINIContent(existing []byte, changes []MergeChange) ([]byte, bool, error) {
+	doc := parseINI(existing)
+	if err := unmergeINI(doc, changes); err != nil {
+		return nil, false, err
+	}
+
+	return doc.bytes(), !doc.empty(), nil
+}
@@ -0,0 +1,582 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// JSON files are merged by editing their text, so comments (JSONC like VS Code's settings.json), trailing commas,
+// key order and formatting of everything sane doesn't touch stay as they are.
+
+//jsonValue a value of a JSON document with its position in the text
+type jsonValue struct {
+	start int
+	end   int
+	// '{' for objects, '[' for arrays, 0 for everything else
+	kind     byte
+	members  []jsonMember
+	elements []*jsonValue
+}
+
+//jsonMember a key of an object. start is the position of the key, the member ends with its value.
+type jsonMember struct {
+	key   string
+	raw   string
+	start int
+	value *jsonValue
+}
+
+type jsonParser struct {
+	text string
+	pos  int
+}
+
+func (p *jsonParser) errorf(format string, args ...interface{}) error {
+	if p.pos > len(p.text) {
+		p.pos = len(p.text)
+	}
+
+	line := strings.Count(p.text[:p.pos], "\n") + 1
+	return fmt.Errorf("invalid json in line %d: "+format, append([]interface{}{line}, args...)...)
+}
+
+//skip whitespace and comments
+func (p *jsonParser) skip() error {
+	for p.pos < len(p.text) {
+		switch {
+		case strings.IndexByte(" \t\r\n", p.text[p.pos]) >= 0:
+			p.pos++
+		case strings.HasPrefix(p.text[p.pos:], "\ufeff"):
+			p.pos += len("\ufeff")
+		case strings.HasPrefix(p.text[p.pos:], "//"):
+			i := strings.IndexByte(p.text[p.pos:], '\n')
+			if i < 0 {
+				p.pos = len(p.text)
+			} else {
+				p.pos += i
+			}
+		case strings.HasPrefix(p.text[p.pos:], "/*"):
+			i := strings.Index(p.text[p.pos+2:], "*/")
+			if i < 0 {
+				return p.errorf("unterminated comment")
+			}
+			p.pos += i + 4
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (p *jsonParser) peek() byte {
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+
+	return 0
+}
+
+func (p *jsonParser) str() (string, error) {
+	start := p.pos
+	p.pos++
+
+	for p.pos < len(p.text) {
+		switch p.text[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			return p.text[start:p.pos], nil
+		case '\n':
+			return "", p.errorf("unterminated string")
+		default:
+			p.pos++
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jsonParser) value() (*jsonValue, error) {
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+
+	v := &jsonValue{start: p.pos}
+
+	switch c := p.peek(); {
+	case c == '{':
+		v.kind = '{'
+		p.pos++
+
+		for {
+			if err := p.skip(); err != nil {
+				return nil, err
+			}
+
+			if p.peek() == '}' {
+				break
+			}
+
+			if p.peek() != '"' {
+				return nil, p.errorf("expected a key")
+			}
+
+			member := jsonMember{start: p.pos}
+
+			raw, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+
+			member.raw = raw
+			if err := json.Unmarshal([]byte(raw), &member.key); err != nil {
+				return nil, p.errorf("invalid key %s", raw)
+			}
+
+			if err := p.skip(); err != nil {
+				return nil, err
+			}
+
+			if p.peek() != ':' {
+				return nil, p.errorf("expected ':' after %s", raw)
+			}
+			p.pos++
+
+			if member.value, err = p.value(); err != nil {
+				return nil, err
+			}
+			v.members = append(v.members, member)
+
+			if err := p.skip(); err != nil {
+				return nil, err
+			}
+
+			// JSONC allows a trailing comma
+			if p.peek() == ',' {
+				p.pos++
+			} else if p.peek() != '}' {
+				return nil, p.errorf("expected ',' or '}'")
+			}
+		}
+		p.pos++
+	case c == '[':
+		v.kind = '['
+		p.pos++
+
+		for {
+			if err := p.skip(); err != nil {
+				return nil, err
+			}
+
+			if p.peek() == ']' {
+				break
+			}
+
+			element, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			v.elements = append(v.elements, element)
+
+			if err := p.skip(); err != nil {
+				return nil, err
+			}
+
+			if p.peek() == ',' {
+				p.pos++
+			} else if p.peek() != ']' {
+				return nil, p.errorf("expected ',' or ']'")
+			}
+		}
+		p.pos++
+	case c == '"':
+		if _, err := p.str(); err != nil {
+			return nil, err
+		}
+	case c != 0 && strings.IndexByte("-0123456789tfn", c) >= 0:
+		for p.pos < len(p.text) && strings.IndexByte(",}] \t\r\n/", p.text[p.pos]) < 0 {
+			p.pos++
+		}
+	default:
+		return nil, p.errorf("unexpected %q", string(c))
+	}
+
+	v.end = p.pos
+	return v, nil
+}
+
+//parseJSONC parse a JSON document which may contain comments and trailing commas
+func parseJSONC(text string) (*jsonValue, error) {
+	p := &jsonParser{text: text}
+
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(text) {
+		return nil, p.errorf("unexpected content after the document")
+	}
+
+	return v, nil
+}
+
+func (v *jsonValue) member(key string) (int, *jsonValue) {
+	for i, m := range v.members {
+		if m.key == key {
+			return i, m.value
+		}
+	}
+
+	return -1, nil
+}
+
+//find the value at a path, nil if it doesn't exist
+func (v *jsonValue) find(path []string) *jsonValue {
+	for _, key := range path {
+		if v == nil || v.kind != '{' {
+			return nil
+		}
+		_, v = v.member(key)
+	}
+
+	return v
+}
+
+//standardJSON the text of a value without comments and trailing commas
+func standardJSON(text string, v *jsonValue) string {
+	switch v.kind {
+	case '{':
+		members := make([]string, 0, len(v.members))
+		for _, m := range v.members {
+			members = append(members, m.raw+":"+standardJSON(text, m.value))
+		}
+		return "{" + strings.Join(members, ",") + "}"
+	case '[':
+		elements := make([]string, 0, len(v.elements))
+		for _, e := range v.elements {
+			elements = append(elements, standardJSON(text, e))
+		}
+		return "[" + strings.Join(elements, ",") + "]"
+	}
+
+	return text[v.start:v.end]
+}
+
+func sameJSON(a string, av *jsonValue, b string, bv *jsonValue) bool {
+	var x, y interface{}
+
+	if decodeJSON([]byte(standardJSON(a, av)), &x) != nil || decodeJSON([]byte(standardJSON(b, bv)), &y) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(x, y)
+}
+
+//renderJSON format a value of the source for the destination, keeping the order of its keys
+func renderJSON(text string, v *jsonValue, indent string, unit string) string {
+	switch {
+	case v.kind == '{' && len(v.members) != 0:
+		members := make([]string, 0, len(v.members))
+		for _, m := range v.members {
+			members = append(members, indent+unit+m.raw+": "+renderJSON(text, m.value, indent+unit, unit))
+		}
+		return "{\n" + strings.Join(members, ",\n") + "\n" + indent + "}"
+	case v.kind == '[' && len(v.elements) != 0:
+		elements := make([]string, 0, len(v.elements))
+		for _, e := range v.elements {
+			elements = append(elements, indent+unit+renderJSON(text, e, indent+unit, unit))
+		}
+		return "[\n" + strings.Join(elements, ",\n") + "\n" + indent + "]"
+	}
+
+	return standardJSON(text, v)
+}
+
+//lineIndent the indentation of the line of pos if nothing but whitespace comes before pos
+func lineIndent(text string, pos int) (string, bool) {
+	start := strings.LastIndexByte(text[:pos], '\n') + 1
+	indent := text[start:pos]
+
+	return indent, strings.Trim(indent, " \t") == ""
+}
+
+//indentUnit the indentation the document uses, two spaces if it can't be told
+func indentUnit(text string, root *jsonValue) string {
+	for _, m := range root.members {
+		if indent, ok := lineIndent(text, m.start); ok && indent != "" {
+			return indent
+		}
+	}
+
+	return "  "
+}
+
+//memberIndent the indentation of the members of an object
+func memberIndent(text string, obj *jsonValue, unit string) string {
+	for _, m := range obj.members {
+		if indent, ok := lineIndent(text, m.start); ok {
+			return indent
+		}
+	}
+
+	start := strings.LastIndexByte(text[:obj.start], '\n') + 1
+	return leadingSpace(text[start:]) + unit
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+//skipInline the position after spaces, an optional comma and a line comment behind pos. Returns whether there is a comma.
+func skipInline(text string, pos int) (int, bool) {
+	comma := false
+
+	for pos < len(text) && (text[pos] == ' ' || text[pos] == '\t') {
+		pos++
+	}
+
+	if pos < len(text) && text[pos] == ',' {
+		comma = true
+		pos++
+	}
+
+	for pos < len(text) && (text[pos] == ' ' || text[pos] == '\t') {
+		pos++
+	}
+
+	if strings.HasPrefix(text[pos:], "//") {
+		pos += strings.IndexByte(text[pos:]+"\n", '\n')
+	}
+
+	return pos, comma
+}
+
+//setJSON set the value at path, the parent object has to exist. value renders the new value for an indentation.
+func setJSON(text string, path []string, value func(indent string) string) (string, error) {
+	root, err := parseJSONC(text)
+	if err != nil {
+		return "", err
+	}
+
+	parent := root.find(path[:len(path)-1])
+	if parent == nil || parent.kind != '{' {
+		return "", errors.New(strings.Join(path[:len(path)-1], ".") + " isn't an object")
+	}
+
+	unit := indentUnit(text, root)
+	indent := memberIndent(text, parent, unit)
+	key, _ := json.Marshal(path[len(path)-1])
+
+	if _, v := parent.member(path[len(path)-1]); v != nil {
+		return text[:v.start] + value(indent) + text[v.end:], nil
+	}
+
+	member := "\n" + indent + string(key) + ": " + value(indent)
+
+	if len(parent.members) == 0 {
+		closing, _ := lineIndent(text, parent.end-1)
+		inner := strings.TrimSpace(text[parent.start+1 : parent.end-1])
+		if inner != "" {
+			// keep comments of the empty object
+			member = "\n" + indent + inner + member
+		}
+		return text[:parent.start+1] + member + "\n" + leadingSpace(closing) + text[parent.end-1:], nil
+	}
+
+	last := parent.members[len(parent.members)-1].value
+	at, comma := skipInline(text, last.end)
+
+	if comma {
+		// keep trailing commas
+		member += ","
+	}
+
+	text = text[:at] + member + text[at:]
+	if !comma {
+		text = text[:last.end] + "," + text[last.end:]
+	}
+
+	return text, nil
+}
+
+//deleteJSON remove the member at path if it exists
+func deleteJSON(text string, path []string) (string, error) {
+	root, err := parseJSONC(text)
+	if err != nil {
+		return "", err
+	}
+
+	parent := root.find(path[:len(path)-1])
+	if parent == nil || parent.kind != '{' {
+		return text, nil
+	}
+
+	i, v := parent.member(path[len(path)-1])
+	if v == nil {
+		return text, nil
+	}
+
+	m := parent.members[i]
+	end, comma := skipInline(text, v.end)
+	start := m.start
+
+	if _, ok := lineIndent(text, m.start); ok && (end == len(text) || text[end] == '\n' || text[end] == '\r') {
+		// the member has its own line
+		start = strings.LastIndexByte(text[:m.start], '\n') + 1
+		if j := strings.IndexByte(text[end:], '\n'); j >= 0 {
+			end += j + 1
+		}
+	} else if !comma {
+		end = v.end
+	}
+
+	// the previous member's comma would be left trailing
+	if i == len(parent.members)-1 && i > 0 && !comma {
+		prev := parent.members[i-1].value
+		if at, prevComma := skipInline(text, prev.end); prevComma {
+			j := strings.IndexByte(text[prev.end:at], ',') + prev.end
+			return text[:j] + text[j+1:start] + text[end:], nil
+		}
+	}
+
+	if len(parent.members) == 1 && strings.TrimSpace(text[parent.start+1:start]+text[end:parent.end-1]) == "" {
+		return text[:parent.start+1] + text[parent.end-1:], nil
+	}
+
+	return text[:start] + text[end:], nil
+}
+
+//mergeJSONObject merge the members of the source object src into the object at path of the destination
+func mergeJSONObject(text string, path []string, content string, src *jsonValue, changes *[]MergeChange) (string, error) {
+	for _, m := range src.members {
+		p := append(append([]string{}, path...), m.key)
+
+		root, err := parseJSONC(text)
+		if err != nil {
+			return "", err
+		}
+
+		existing := root.find(p)
+		if existing != nil && existing.kind == '{' && m.value.kind == '{' {
+			if text, err = mergeJSONObject(text, p, content, m.value, changes); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		if existing != nil && sameJSON(text, existing, content, m.value) {
+			continue
+		}
+
+		change := MergeChange{Path: p, Existed: existing != nil}
+		if existing != nil {
+			change.Old = text[existing.start:existing.end]
+		}
+
+		unit := indentUnit(text, root)
+		text, err = setJSON(text, p, func(indent string) string {
+			return renderJSON(content, m.value, indent, unit)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		*changes = append(*changes, change)
+	}
+
+	return text, nil
+}
+
+//unmergeJSON put back the values the recorded changes replaced and remove the keys sane added
+func unmergeJSON(text string, changes []MergeChange) (string, error) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+
+		root, err := parseJSONC(text)
+		if err != nil {
+			return "", err
+		}
+
+		if parent := root.find(change.Path[:len(change.Path)-1]); parent == nil || parent.kind != '{' {
+			// removed by the user in the meantime
+			continue
+		}
+
+		if !change.Existed {
+			text, err = deleteJSON(text, change.Path)
+		} else {
+			text, err = setJSON(text, change.Path, func(string) string { return change.Old })
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return text, nil
+}
+
+func parseJSONObject(text string) (*jsonValue, error) {
+	v, err := parseJSONC(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.kind != '{' {
+		return nil, errors.New("only json objects can be merged")
+	}
+
+	return v, nil
+}
+
+//mergeJSON merge the keys of content into a JSON file by editing its text
+func mergeJSON(existing []byte, content []byte, previous []MergeChange) ([]byte, []MergeChange, error) {
+	text := string(existing)
+	if strings.TrimSpace(text) == "" {
+		text = "{}\n"
+	}
+
+	if _, err := parseJSONObject(text); err != nil {
+		return nil, nil, err
+	}
+
+	text, err := unmergeJSON(text, previous)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	src, err := parseJSONObject(string(content))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := make([]MergeChange, 0)
+	text, err = mergeJSONObject(text, []string{}, string(content), src, &changes)
+
+	return []byte(text), changes, err
+}
+
+//unmergeJSONContent remove the changes sane made from a merged JSON file. Returns whether anything is left.
+func unmergeJSONContent(existing []byte, changes []MergeChange) ([]byte, bool, error) {
+	text, err := unmergeJSON(string(existing), changes)
+	if err != nil {
+		return nil, false, err
+	}
+
+	root, err := parseJSONObject(text)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return []byte(text), len(root.members) != 0, nil
+}
@@ -3,16 +3,15 @@ package src
 import (
 	"errors"
 	"fmt"
-	"github.com/hacdias/fileutils"
 	"io/ioutil"
 	"math"
 	"os"
 	"os/exec"
 	"path"
-	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -28,7 +27,18 @@ func hasSaneYml(repo Repo, home string) string {
 	return target
 }
 
-func startDockerCompose(m map[string]interface{}, repo Repo, home string) {
+func readSaneYml(repo Repo, home string) map[string]interface{} {
+	target := hasSaneYml(repo, home)
+	b, _ := ioutil.ReadFile(target)
+	m := make(map[string]interface{})
+
+	err := yaml.Unmarshal(b, &m)
+	CheckCouldntParse(err, "")
+
+	return m
+}
+
+func startDockerCompose(m map[string]interface{}, repo Repo, home string, services []string) {
 	if file, ok := m["file"]; ok {
 		dockerComposeFile := path.Join(home, GetRepoFolder(repo), file.(string))
 
@@ -44,11 +54,13 @@ func startDockerCompose(m map[string]interface{}, repo Repo, home string) {
 			}
 		}
 
+		cmd.Args = append(cmd.Args, services...)
+
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 
-		_ = cmd.Run()
+		_ = runCmd(cmd)
 	} else {
 		fmt.Println("❌  Docker compose file not set!")
 		os.Exit(1)
@@ -61,109 +73,388 @@ func checkDebugCmd(cmd *exec.Cmd) {
 	}
 }
 
-func startDocker(m map[string]interface{}) {
-	configs := extractDockerConfig(m)
+func dockerRunCmd(dockerConfig DockerConfig, repo Repo) *exec.Cmd {
+	cmd := exec.Command("docker", "run")
 
-	started := make([]DockerConfig, 0)
+	if dockerConfig.Deamon {
+		cmd.Args = append(cmd.Args, "-d")
+	}
 
-	sort.SliceStable(configs, func(i, j int) bool {
-		return configs[i].Start < configs[j].Start
-	})
+	cmd.Args = append(cmd.Args, "--name")
+	cmd.Args = append(cmd.Args, dockerConfig.Name)
 
-	for _, dockerConfig := range configs {
-		cmd := exec.Command("docker", "run")
+	cmd.Args = append(cmd.Args, "--label")
+	cmd.Args = append(cmd.Args, ManagedLabel)
+	cmd.Args = append(cmd.Args, "--label")
+	cmd.Args = append(cmd.Args, ConfigLabel+"="+GetRepoString(repo))
 
-		if dockerConfig.Deamon {
-			cmd.Args = append(cmd.Args, "-d")
-		}
+	if dockerConfig.Net != "" {
+		cmd.Args = append(cmd.Args, "--net")
+		cmd.Args = append(cmd.Args, dockerConfig.Net)
+	}
 
-		cmd.Args = append(cmd.Args, "--name")
-		cmd.Args = append(cmd.Args, dockerConfig.Name)
+	for _, link := range dockerConfig.Links {
+		cmd.Args = append(cmd.Args, "--link")
+		cmd.Args = append(cmd.Args, link)
+	}
+
+	if dockerConfig.Ipc != "" {
+		cmd.Args = append(cmd.Args, "--ipc")
+		cmd.Args = append(cmd.Args, dockerConfig.Ipc)
+	}
+
+	if dockerConfig.Pid != "" {
+		cmd.Args = append(cmd.Args, "--pid")
+		cmd.Args = append(cmd.Args, dockerConfig.Pid)
+	}
+
+	for _, port := range dockerConfig.Ports {
+		cmd.Args = append(cmd.Args, "-p")
+		cmd.Args = append(cmd.Args, port.Source+":"+port.Target)
+	}
+
+	for _, volume := range dockerConfig.Volumes {
+		cmd.Args = append(cmd.Args, "--volume")
+		cmd.Args = append(cmd.Args, volume.Source+":"+volume.Target)
+	}
 
-		if dockerConfig.Net != "" {
-			cmd.Args = append(cmd.Args, "--net")
-			cmd.Args = append(cmd.Args, dockerConfig.Net)
+	if dockerConfig.Interactive {
+		cmd.Stderr = os.Stderr
+		cmd.Stdout = os.Stdout
+		cmd.Stdin = os.Stdin
+		cmd.Args = append(cmd.Args, "-it")
+	}
+
+	for _, env := range dockerConfig.Environment {
+		cmd.Args = append(cmd.Args, "--env")
+
+		if strings.Contains(env.Value, " ") {
+			env.Value = "\"" + env.Value + "\""
 		}
 
-		if dockerConfig.Ipc != "" {
-			cmd.Args = append(cmd.Args, "--ipc")
-			cmd.Args = append(cmd.Args, dockerConfig.Ipc)
+		cmd.Args = append(cmd.Args, env.Key+"="+env.Value)
+	}
+
+	cmd.Args = append(cmd.Args, dockerConfig.Image)
+	checkDebugCmd(cmd)
+
+	return cmd
+}
+
+//selectContainers filter configs by container names. Returns all configs if no names are given.
+func selectContainers(configs []DockerConfig, names []string) []DockerConfig {
+	if len(names) == 0 {
+		return configs
+	}
+
+	selected := make([]DockerConfig, 0)
+
+	for _, name := range names {
+		found := false
+
+		for _, dockerConfig := range configs {
+			if dockerConfig.Name == name {
+				selected = append(selected, dockerConfig)
+				found = true
+				break
+			}
 		}
 
-		if dockerConfig.Pid != "" {
-			cmd.Args = append(cmd.Args, "--pid")
-			cmd.Args = append(cmd.Args, dockerConfig.Pid)
+		if !found {
+			fmt.Println("❌  Container '" + name + "' not found!")
+			os.Exit(1)
 		}
+	}
+
+	return selected
+}
 
-		for _, port := range dockerConfig.Ports {
-			cmd.Args = append(cmd.Args, "-p")
-			cmd.Args = append(cmd.Args, port.Source+":"+port.Target)
+//containerDependencies the containers of configs c needs: the ones it links to and the ones with an earlier start order
+func containerDependencies(configs []DockerConfig, c DockerConfig) []string {
+	dependencies := append([]string{}, c.Links...)
+
+	for _, other := range configs {
+		if other.Start < c.Start && c.Start != math.MaxInt32 && !ContainsString(dependencies, other.Name) {
+			dependencies = append(dependencies, other.Name)
 		}
+	}
 
-		for _, volume := range dockerConfig.Volumes {
-			cmd.Args = append(cmd.Args, "--volume")
-			cmd.Args = append(cmd.Args, volume.Source+":"+volume.Target)
+	return dependencies
+}
+
+//containerDependents the containers of configs which need c
+func containerDependents(configs []DockerConfig, c DockerConfig) []string {
+	dependents := make([]string, 0)
+
+	for _, other := range configs {
+		if ContainsString(containerDependencies(configs, other), c.Name) {
+			dependents = append(dependents, other.Name)
 		}
+	}
+
+	return dependents
+}
 
-		if dockerConfig.Interactive {
-			cmd.Stderr = os.Stderr
-			cmd.Stdout = os.Stdout
-			cmd.Stdin = os.Stdin
-			cmd.Args = append(cmd.Args, "-it")
+//withRelated add what related returns for the selected containers until nothing new comes up
+func withRelated(configs []DockerConfig, selected []DockerConfig, related func(DockerConfig) []string) []DockerConfig {
+	result := append([]DockerConfig{}, selected...)
+
+	for i := 0; i < len(result); i++ {
+		for _, name := range related(result[i]) {
+			if _, err := findContainer(result, name); err == nil {
+				continue
+			}
+
+			if c, err := findContainer(configs, name); err == nil {
+				result = append(result, c)
+			}
 		}
+	}
 
-		for _, env := range dockerConfig.Environment {
-			cmd.Args = append(cmd.Args, "--env")
+	return result
+}
 
-			if strings.Contains(env.Value, " ") {
-				env.Value = "\"" + env.Value + "\""
+//orderContainers order configs so every container comes after the ones first returns for it
+func orderContainers(configs []DockerConfig, first func(DockerConfig) []string) []DockerConfig {
+	ordered := make([]DockerConfig, 0, len(configs))
+	visited := make(map[string]bool)
+
+	var visit func(c DockerConfig)
+	visit = func(c DockerConfig) {
+		if visited[c.Name] {
+			return
+		}
+		visited[c.Name] = true
+
+		for _, name := range first(c) {
+			if other, err := findContainer(configs, name); err == nil {
+				visit(other)
 			}
+		}
+
+		ordered = append(ordered, c)
+	}
+
+	for _, c := range configs {
+		visit(c)
+	}
 
-			cmd.Args = append(cmd.Args, env.Key+"="+env.Value)
+	return ordered
+}
+
+//ReportFailures print the containers that failed to start/stop and exit if there were any
+func ReportFailures(action string, failed []string) {
+	if len(failed) != 0 {
+		fmt.Println("❌  " + strconv.Itoa(len(failed)) + " container(s) failed to " + action + ": " + strings.Join(failed, ", "))
+		os.Exit(1)
+	}
+}
+
+func startDocker(m map[string]interface{}, repo Repo, names []string, keepGoing bool) []string {
+	state := ReadState()
+	instance, running := state.Instances[GetRepoString(repo)]
+
+	if !running {
+		instance = Instance{
+			Repo:       repo,
+			Containers: make([]DockerConfig, 0),
+			Started:    time.Now(),
+		}
+	}
+
+	all := extractDockerConfig(m)
+	configs := make([]DockerConfig, 0)
+
+	// the containers the named ones depend on are started as well
+	selected := withRelated(all, selectContainers(all, names), func(c DockerConfig) []string {
+		return containerDependencies(all, c)
+	})
+
+	for _, dockerConfig := range selected {
+		if _, err := findContainer(instance.Containers, dockerConfig.Name); err == nil {
+			fmt.Println("👌  Container '" + dockerConfig.Name + "' is already running")
+			continue
+		}
+
+		configs = append(configs, dockerConfig)
+	}
+
+	started := make([]DockerConfig, 0)
+	failed := make([]string, 0)
+
+	rollback := func() {
+		for _, s := range started {
+			_ = exec.Command("docker", "stop", s.Name).Run()
+			_ = exec.Command("docker", "rm", s.Name).Run()
+		}
+		WriteState(state)
+		os.Exit(1)
+	}
+
+	sort.SliceStable(configs, func(i, j int) bool {
+		return configs[i].Start < configs[j].Start
+	})
+	configs = orderContainers(configs, func(c DockerConfig) []string {
+		return containerDependencies(all, c)
+	})
+
+	instance.Hooks = extractHooks(m)
+
+	if len(configs) != 0 {
+		checkHooks(instance.Hooks, PreStart, repo)
+	}
+
+	allocatePorts(configs)
+
+	for _, dockerConfig := range configs {
+		cmd := dockerRunCmd(dockerConfig, repo)
+
+		ensureNetwork(dockerConfig.Net)
+		for _, volume := range dockerConfig.Volumes {
+			ensureVolume(volume.Source)
 		}
 
-		cmd.Args = append(cmd.Args, dockerConfig.Image)
-		checkDebugCmd(cmd)
+		pulled := !imageExists(dockerConfig.Image)
 
 		fmt.Println("🐳  Starting container '" + dockerConfig.Name + "'...")
-		err := cmd.Run()
+		err := runCmd(cmd)
+
+		if pulled && imageExists(dockerConfig.Image) && !ContainsString(state.Images, dockerConfig.Image) {
+			state.Images = append(state.Images, dockerConfig.Image)
+		}
 
 		if err != nil {
-			fmt.Println("❌  There was an error while starting the container! Rolling back...")
-			for _, s := range started {
-				_ = exec.Command("docker", "stop", s.Name).Run()
-				_ = exec.Command("docker", "rm", s.Name).Run()
+			if keepGoing {
+				fmt.Println("❌  There was an error while starting the container '" + dockerConfig.Name + "'!")
+				failed = append(failed, dockerConfig.Name)
+				continue
 			}
-			os.Exit(1)
+
+			fmt.Println("❌  There was an error while starting the container! Rolling back...")
+			rollback()
 		}
 
 		started = append(started, dockerConfig)
 	}
+
+	if len(started) != 0 {
+		if err := runHooks(instance.Hooks, PostStart, repo); err != nil {
+			fmt.Println("❌  " + err.Error() + ". Rolling back...")
+			rollback()
+		}
+	}
+
+	if len(started) != 0 {
+		instance.Containers = append(instance.Containers, started...)
+		state.Instances[GetRepoString(repo)] = instance
+	}
+
+	WriteState(state)
+
+	if len(started) != 0 {
+		fmt.Println()
+		printEndpoints(started)
+	}
+
+	return failed
 }
 
-func stopDocker(m map[string]interface{}) {
-	configs := extractDockerConfig(m)
+func stopDocker(m map[string]interface{}, repo Repo, names []string, keepGoing bool) []string {
+	all := extractDockerConfig(m)
+	selected := selectContainers(all, names)
+
+	// running containers which depend on the named ones are stopped first
+	instance := ReadState().Instances[GetRepoString(repo)]
+	running := make([]DockerConfig, 0)
+	for _, c := range all {
+		if _, err := findContainer(instance.Containers, c.Name); err == nil {
+			running = append(running, c)
+		}
+	}
+
+	configs := withRelated(running, selected, func(c DockerConfig) []string {
+		return containerDependents(all, c)
+	})
+
+	for _, c := range configs[len(selected):] {
+		fmt.Println("✋  Stopping '" + c.Name + "' as well, it depends on " + strings.Join(containerDependencies(all, c), ", "))
+	}
 
+	return stopContainers(configs, repo, keepGoing)
+}
+
+func stopContainers(configs []DockerConfig, repo Repo, keepGoing bool) []string {
 	sort.SliceStable(configs, func(i, j int) bool {
 		return configs[i].Stop < configs[j].Stop
 	})
+	configs = orderContainers(configs, func(c DockerConfig) []string {
+		return containerDependents(configs, c)
+	})
+
+	stopped := make(map[string]bool)
+	failed := make([]string, 0)
+	hooks := ReadState().Instances[GetRepoString(repo)].Hooks
+
+	if len(configs) != 0 {
+		checkHooks(hooks, PreStop, repo)
+	}
+
+	writeStopped := func() {
+		state := ReadState()
+
+		if instance, ok := state.Instances[GetRepoString(repo)]; ok {
+			remaining := make([]DockerConfig, 0)
+
+			for _, c := range instance.Containers {
+				if !stopped[c.Name] {
+					remaining = append(remaining, c)
+				}
+			}
+
+			if len(remaining) == 0 {
+				delete(state.Instances, GetRepoString(repo))
+			} else {
+				instance.Containers = remaining
+				state.Instances[GetRepoString(repo)] = instance
+			}
+
+			WriteState(state)
+		}
+	}
 
 	for _, dockerConfig := range configs {
 		fmt.Println("🐳  Stopping container '" + dockerConfig.Name + "'...")
 
 		cmd1 := exec.Command("docker", "stop", dockerConfig.Name)
 		checkDebugCmd(cmd1)
-		err1 := cmd1.Run()
+		err1 := runCmd(cmd1)
 
 		cmd2 := exec.Command("docker", "rm", dockerConfig.Name)
 		checkDebugCmd(cmd2)
-		err2 := cmd2.Run()
+		err2 := runCmd(cmd2)
 
 		if err1 != nil || err2 != nil {
+			if keepGoing {
+				fmt.Println("❌  There was an error while stopping the container '" + dockerConfig.Name + "'!")
+				failed = append(failed, dockerConfig.Name)
+				continue
+			}
+
+			writeStopped()
 			fmt.Println("❌  There was an error while stopping the container!")
 			os.Exit(1)
 		}
+
+		stopped[dockerConfig.Name] = true
+	}
+
+	writeStopped()
+
+	if len(configs) != 0 {
+		checkHooks(hooks, PostStop, repo)
 	}
+
+	return failed
 }
 
 func extractDockerConfig(m map[string]interface{}) []DockerConfig {
@@ -215,6 +506,12 @@ func extractDockerConfig(m map[string]interface{}) []DockerConfig {
 			cfg.Stop = stop.(int)
 		}
 
+		if links, ok := vals["links"]; ok {
+			for _, link := range links.([]interface{}) {
+				cfg.Links = append(cfg.Links, link.(string))
+			}
+		}
+
 		if env, ok := vals["environment"]; ok {
 			for _, e := range env.([]interface{}) {
 				for envK, envV := range e.(map[interface{}]interface{}) {
@@ -254,20 +551,19 @@ func extractDockerConfig(m map[string]interface{}) []DockerConfig {
 	return dockerConfigs
 }
 
-//StartConfig start a container or a docker compose file
-func StartConfig(repo Repo, home string) {
-	target := hasSaneYml(repo, home)
-	b, _ := ioutil.ReadFile(target)
-	m := make(map[string]interface{})
-	err := yaml.Unmarshal(b, &m)
-	CheckCouldntParse(err, "")
+//StartConfig start all or the given containers or a docker compose file. Returns the containers that failed to start.
+func StartConfig(repo Repo, home string, containers []string, keepGoing bool) []string {
+	m := readSaneYml(repo, home)
 
 	if val, ok := m["mode"]; ok {
 		switch val.(string) {
 		case "docker":
-			startDocker(m)
+			return startDocker(m, repo, containers, keepGoing)
 		case "docker-compose":
-			startDockerCompose(m, repo, home)
+			checkHooks(extractHooks(m), PreStart, repo)
+			startDockerCompose(m, repo, home, containers)
+		case "script":
+			runScriptAction(m, repo, START)
 		default:
 			fmt.Println("❌  Unsupported start mode \"" + val.(string) + "\"!")
 			os.Exit(1)
@@ -276,137 +572,316 @@ func StartConfig(repo Repo, home string) {
 		fmt.Println("❌  Config mode not set!")
 		os.Exit(1)
 	}
+
+	return nil
 }
 
-//StopConfig stop a container
-func StopConfig(repo Repo, home string) {
-	target := hasSaneYml(repo, home)
-	b, _ := ioutil.ReadFile(target)
-	m := make(map[string]interface{})
-	err := yaml.Unmarshal(b, &m)
-	CheckCouldntParse(err, "")
+//RestartConfig stop the running containers of a config (or the given ones) and start them again. Returns the containers that failed to restart.
+func RestartConfig(repo Repo, home string, containers []string, keepGoing bool) []string {
+	failed := make([]string, 0)
 
-	if val, ok := m["mode"]; ok {
-		switch val.(string) {
-		case "docker":
-			stopDocker(m)
-		default:
-			fmt.Println("❌  Unsupported stop mode \"" + val.(string) + "\"!")
-			os.Exit(1)
+	if instance, ok := ReadState().Instances[GetRepoString(repo)]; ok {
+		running := make([]string, 0)
+
+		// named containers which aren't running are only started
+		for _, c := range instance.Containers {
+			if len(containers) == 0 || ContainsString(containers, c.Name) {
+				running = append(running, c.Name)
+			}
+		}
+
+		if len(running) != 0 {
+			failed = append(failed, StopConfig(repo, home, running, keepGoing)...)
+		}
+
+		// dependents which were stopped with the named containers are started again
+		if len(containers) != 0 {
+			left := ReadState().Instances[GetRepoString(repo)].Containers
+			for _, c := range instance.Containers {
+				if _, err := findContainer(left, c.Name); err != nil && !ContainsString(containers, c.Name) {
+					containers = append(containers, c.Name)
+				}
+			}
 		}
-	} else {
-		fmt.Println("❌  Config mode not set!")
-		os.Exit(1)
 	}
+
+	return append(failed, StartConfig(repo, home, containers, keepGoing)...)
 }
 
-func extractFileConfig(m map[string]interface{}) map[string]string {
-	fileMap := make(map[string]string)
+//StopAll stop every running config and every other running container labelled as managed by sane. Returns the containers that failed to stop.
+func StopAll(keepGoing bool) []string {
+	failed := make([]string, 0)
+	instances := ReadState().Instances
 
-	if files, ok := m["files"]; ok {
-		for _, v := range files.([]interface{}) {
-			m := v.(map[interface{}]interface{})
-			fileMap[m["file"].(string)] = os.ExpandEnv(m[runtime.GOOS].(string))
-		}
-	} else {
-		fmt.Println("❌  Files not found!")
+	// containers which aren't in the state (anymore) are found by their labels
+	labelled, err := dockerLines("ps", "--filter", "label="+ManagedLabel, "--format", "{{.Names}}\t{{.Label \""+ConfigLabel+"\"}}")
+	if err != nil {
+		fmt.Println("❌  Couldn't list the running containers: " + err.Error())
 		os.Exit(1)
 	}
 
-	return fileMap
-}
+	for key, instance := range instances {
+		fmt.Println("✋  Stopping " + key + "...")
+		failed = append(failed, stopContainers(instance.Containers, instance.Repo, keepGoing)...)
+	}
+
+	unknown := make(map[string][]DockerConfig)
+	for _, line := range labelled {
+		parts := strings.SplitN(line+"\t", "\t", 3)
+		name, key := parts[0], parts[1]
+
+		tracked := false
+		for _, instance := range instances {
+			if _, err := findContainer(instance.Containers, name); err == nil {
+				tracked = true
+			}
+		}
+
+		if !tracked {
+			unknown[key] = append(unknown[key], DockerConfig{Name: name, Start: math.MaxInt32, Stop: math.MaxInt32})
+		}
+	}
 
-func applyConfig(m map[string]interface{}, repo Repo, home string) {
-	files := extractFileConfig(m)
-	for src, dst := range files {
-		err := os.Rename(dst, dst+".backup")
-		CheckWithMessage(err, "❌  There was an error while moving a file!")
+	keys := make([]string, 0, len(unknown))
+	for key := range unknown {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-		target := path.Join(home, GetRepoFolder(repo), src)
+	for _, key := range keys {
+		repo, name := Repo{}, "containers without a config"
+		if repoExp.MatchString(key) {
+			repo, name = GetRepoFromString(key), key
+		}
 
-		err = fileutils.CopyFile(target, dst)
-		CheckWithMessage(err, "❌  There was an error while moving a file!")
+		fmt.Println("✋  Stopping " + name + "...")
+		failed = append(failed, stopContainers(unknown[key], repo, keepGoing)...)
 	}
+
+	return failed
 }
 
-func removeConfig(m map[string]interface{}) {
-	files := extractFileConfig(m)
-	for _, dst := range files {
-		err := os.Remove(dst)
-		CheckWithMessage(err, "❌  There was an error while deleting a file!")
+//StopConfig stop all or the given containers. Returns the containers that failed to stop.
+func StopConfig(repo Repo, home string, containers []string, keepGoing bool) []string {
+	m := readSaneYml(repo, home)
 
-		err = os.Rename(dst+".backup", dst)
-		CheckWithMessage(err, "❌  There was an error while moving a file!")
+	if val, ok := m["mode"]; ok {
+		switch val.(string) {
+		case "docker":
+			return stopDocker(m, repo, containers, keepGoing)
+		case "script":
+			runScriptAction(m, repo, STOP)
+		default:
+			fmt.Println("❌  Unsupported stop mode \"" + val.(string) + "\"!")
+			os.Exit(1)
+		}
+	} else {
+		fmt.Println("❌  Config mode not set!")
+		os.Exit(1)
 	}
+
+	return nil
 }
 
-func applyAliases(m map[string]interface{}, cfg SaneConfig) {
+func applyAliases(m map[string]interface{}, cfg SaneConfig, entry LedgerEntry, tx *transaction) LedgerEntry {
+	entry.Aliases = make(map[string]string)
+	previous := make(map[string]string)
+
+	for k, v := range cfg.Aliases {
+		previous[k] = v
+	}
+
 	if aliases, ok := m["aliases"]; ok {
 		for _, v := range aliases.([]interface{}) {
 			for k, v1 := range v.(map[interface{}]interface{}) {
 				cfg.Aliases[k.(string)] = v1.(string)
+				entry.Aliases[k.(string)] = v1.(string)
+
+				if DryRun {
+					plan("alias", k.(string)+" => "+v1.(string))
+				}
 			}
 		}
 
 		fmt.Println("🎭  Writing aliases...")
 		WriteConfig(cfg)
+
+		tx.undo = append(tx.undo, func() error {
+			cfg.Aliases = previous
+			WriteConfig(cfg)
+			return nil
+		})
 	} else {
 		fmt.Println("❌  Aliases not found!")
 		os.Exit(1)
 	}
+
+	return entry
 }
 
-func removeAliases(m map[string]interface{}, cfg SaneConfig) {
-	if aliases, ok := m["aliases"]; ok {
-		for _, v := range aliases.([]interface{}) {
-			for k := range v.(map[interface{}]interface{}) {
-				delete(cfg.Aliases, k.(string))
+func removeAliases(entry LedgerEntry, cfg SaneConfig, tx *transaction) {
+	previous := make(map[string]string)
+
+	for k, v := range cfg.Aliases {
+		previous[k] = v
+	}
+
+	for k, v := range entry.Aliases {
+		// don't remove aliases that were changed by the user
+		if cfg.Aliases[k] == v {
+			delete(cfg.Aliases, k)
+
+			if DryRun {
+				plan("unalias", k)
 			}
 		}
+	}
 
-		fmt.Println("🎭  Writing aliases...")
+	fmt.Println("🎭  Writing aliases...")
+	WriteConfig(cfg)
+
+	tx.undo = append(tx.undo, func() error {
+		cfg.Aliases = previous
 		WriteConfig(cfg)
-	} else {
-		fmt.Println("❌  Aliases not found!")
-		os.Exit(1)
-	}
+		return nil
+	})
 }
 
-func doConfig(mode string, m map[string]interface{}, repo Repo, home string) {
-	if mode == APPLY {
-		applyConfig(m, repo, home)
-	} else {
-		removeConfig(m)
-	}
-}
+//legacyEntry build the ledger entry of a config applied by a version of sane without a ledger from its sanefile.
+//Those versions moved the original of a file to <file>.backup and copied the file in its place.
+func legacyEntry(repo Repo, home string, cfg SaneConfig) (LedgerEntry, bool) {
+	m := readSaneYml(repo, home)
+	entry := LedgerEntry{Repo: repo, Files: make([]LedgerFile, 0), Directories: make([]string, 0)}
+	entry.Mode, _ = m["mode"].(string)
+
+	switch entry.Mode {
+	case "config":
+		for _, f := range extractFileConfig(m, path.Join(home, GetRepoFolder(repo)), templateData(m, cfg)) {
+			backup := f.Destination + ".backup"
+			if _, err := os.Stat(backup); err != nil {
+				continue
+			}
 
-func doAliases(mode string, m map[string]interface{}, cfg SaneConfig) {
-	if mode == APPLY {
-		applyAliases(m, cfg)
-	} else {
-		removeAliases(m, cfg)
+			// there is no record of what was applied, so the destination counts as unchanged
+			hash, _ := FileHash(f.Destination)
+			meta, err := readMeta(backup)
+			CheckWithMessage(err, "❌  There was an error while reading "+backup+"!")
+
+			entry.Files = append(entry.Files, LedgerFile{
+				Source:      f.Source,
+				Destination: f.Destination,
+				Strategy:    CopyStrategy,
+				Hash:        hash,
+				Backup:      backup,
+				Original:    meta,
+			})
+		}
+
+		return entry, len(entry.Files) != 0
+	case "aliases":
+		entry.Aliases = make(map[string]string)
+
+		if aliases, ok := m["aliases"]; ok {
+			for _, v := range aliases.([]interface{}) {
+				for k, v1 := range v.(map[interface{}]interface{}) {
+					entry.Aliases[k.(string)] = v1.(string)
+				}
+			}
+		}
+
+		return entry, len(entry.Aliases) != 0
 	}
+
+	return entry, false
 }
 
 //DoConfig apply/remove a config or a list of aliases
 func DoConfig(repo Repo, home string, cfg SaneConfig, mode string) {
-	target := hasSaneYml(repo, home)
-	b, _ := ioutil.ReadFile(target)
-	m := make(map[string]interface{})
+	ledger := ReadLedger()
+	entry, applied := ledger.Entries[GetRepoString(repo)]
 
-	err := yaml.Unmarshal(b, &m)
-	CheckCouldntParse(err, "")
+	if mode == REMOVE {
+		if !applied {
+			entry, applied = legacyEntry(repo, home, cfg)
+		}
+
+		if !applied {
+			fmt.Println("🤷  " + GetRepoString(repo) + " is not applied!")
+			os.Exit(1)
+		}
+
+		checkHooks(entry.Hooks, PreRemove, repo)
+
+		tx := &transaction{}
+		backups := make([]BackupFile, 0)
+
+		switch entry.Mode {
+		case "config", "dotfiles", "binary":
+			backups = removeConfig(entry, tx)
+		case "aliases":
+			removeAliases(entry, cfg, tx)
+		case "script":
+			checkScript(repo, REMOVE, entry.Scripts, removeVars(repo, home, cfg, entry))
+		case "packages":
+			removePackages(entry, tx)
+		}
+
+		if err := runHooks(entry.Hooks, PostRemove, repo); err != nil {
+			tx.check(err, "❌  "+err.Error())
+		}
+
+		removeEmptyDirs(entry.Directories)
+		writeGeneration(repo, REMOVE, backups)
+
+		delete(ledger.Entries, GetRepoString(repo))
+		WriteLedger(ledger)
+		return
+	}
+
+	m := readSaneYml(repo, home)
 
 	if val, ok := m["mode"]; ok {
+		entry.Repo = repo
+		entry.Mode = val.(string)
+		entry.Commit = GetRepoCommit(repo, home)
+		entry.Applied = time.Now()
+		entry.Hooks = extractHooks(m)
+
+		if !ContainsString([]string{"config", "dotfiles", "binary", "aliases", "script", "packages"}, val.(string)) {
+			fmt.Println("❌  Unsupported start mode \"" + val.(string) + "\"!")
+			os.Exit(1)
+		}
+
+		checkHooks(entry.Hooks, PreApply, repo)
+
+		tx := &transaction{}
+		backups := make([]BackupFile, 0)
+
 		switch val.(string) {
 		case "config":
-			doConfig(mode, m, repo, home)
+			entry, backups = applyConfig(m, repo, home, cfg, entry, tx)
+		case "dotfiles":
+			entry, backups = applyDotfiles(m, repo, home, cfg, entry, tx)
+		case "binary":
+			entry, backups = applyBinaries(m, repo, cfg, entry, tx)
 		case "aliases":
-			doAliases(mode, m, cfg)
-		default:
-			fmt.Println("❌  Unsupported start mode \"" + val.(string) + "\"!")
-			os.Exit(1)
+			entry = applyAliases(m, cfg, entry, tx)
+		case "script":
+			entry = applyScript(m, repo, cfg, entry)
+		case "packages":
+			entry = applyPackages(m, entry, tx)
+		}
+
+		if err := runHooks(entry.Hooks, PostApply, repo); err != nil {
+			tx.check(err, "❌  "+err.Error())
+		}
+
+		if tx.changed() {
+			writeGeneration(repo, APPLY, backups)
 		}
+
+		ledger.Entries[GetRepoString(repo)] = entry
+		WriteLedger(ledger)
 	} else {
 		fmt.Println("❌  Config mode not set!")
 		os.Exit(1)
@@ -0,0 +1,62 @@
+package src
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func containerNames(configs []DockerConfig) []string {
+	names := make([]string, 0, len(configs))
+	for _, c := range configs {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestContainerDependencies(t *testing.T) {
+	all := []DockerConfig{
+		{Name: "kafka", Start: 2, Stop: math.MaxInt32},
+		{Name: "ui", Links: []string{"kafka"}, Start: math.MaxInt32, Stop: math.MaxInt32},
+		{Name: "zookeeper", Start: 1, Stop: math.MaxInt32},
+		{Name: "mail", Start: math.MaxInt32, Stop: math.MaxInt32},
+	}
+
+	dependencies := func(c DockerConfig) []string { return containerDependencies(all, c) }
+	dependents := func(c DockerConfig) []string { return containerDependents(all, c) }
+
+	tests := []struct {
+		name    string
+		related func(DockerConfig) []string
+		names   []string
+		want    []string
+	}{
+		{"start kafka", dependencies, []string{"kafka"}, []string{"zookeeper", "kafka"}},
+		{"start ui", dependencies, []string{"ui"}, []string{"zookeeper", "kafka", "ui"}},
+		{"start mail", dependencies, []string{"mail"}, []string{"mail"}},
+		{"stop zookeeper", dependents, []string{"zookeeper"}, []string{"ui", "kafka", "zookeeper"}},
+		{"stop kafka", dependents, []string{"kafka"}, []string{"ui", "kafka"}},
+		{"stop ui", dependents, []string{"ui"}, []string{"ui"}},
+	}
+
+	for _, test := range tests {
+		configs := withRelated(all, selectContainers(all, test.names), test.related)
+		got := containerNames(orderContainers(configs, test.related))
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestOrderContainersCycle(t *testing.T) {
+	all := []DockerConfig{
+		{Name: "a", Links: []string{"b"}, Start: math.MaxInt32},
+		{Name: "b", Links: []string{"a"}, Start: math.MaxInt32},
+	}
+
+	got := orderContainers(all, func(c DockerConfig) []string { return containerDependencies(all, c) })
+	if len(got) != 2 {
+		t.Errorf("ordering linked containers = %v, want both", containerNames(got))
+	}
+}
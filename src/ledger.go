@@ -0,0 +1,91 @@
+package src
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+//LedgerFile a file written by sane when applying a config
+type LedgerFile struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Strategy    string `json:"strategy"`
+	Template    bool   `json:"template"`
+	Format      string `json:"format"`
+	Hash        string `json:"hash"`
+	// Backup hash of the original in the backup store, empty if there was none
+	Backup string `json:"backup"`
+	// Original permissions and ownership of the original, nil if there was none
+	Original *FileMeta `json:"original,omitempty"`
+	// Changes keys set by the merge strategy
+	Changes []MergeChange `json:"changes"`
+	// Comment prefix of the markers of the block strategy
+	Comment string `json:"comment,omitempty"`
+	// Privileged changed through the elevation command
+	Privileged bool `json:"privileged,omitempty"`
+}
+
+//LedgerEntry an applied config
+type LedgerEntry struct {
+	Repo        Repo                `json:"repo"`
+	Mode        string              `json:"mode"`
+	Commit      string              `json:"commit"`
+	Files       []LedgerFile        `json:"files"`
+	Directories []string            `json:"directories"`
+	Aliases     map[string]string   `json:"aliases"`
+	Hooks       map[string][]Hook   `json:"hooks,omitempty"`
+	Scripts     map[string]Script   `json:"scripts,omitempty"`
+	Vars        map[string]string   `json:"vars,omitempty"`
+	Log         string              `json:"log,omitempty"`
+	Packages    map[string][]string `json:"packages,omitempty"`
+	Applied     time.Time           `json:"applied"`
+}
+
+//Ledger all applied configs by repo string
+type Ledger struct {
+	Entries map[string]LedgerEntry `json:"entries"`
+}
+
+//ReadLedger read the apply ledger. Returns an empty ledger if none was written yet.
+func ReadLedger() Ledger {
+	ledger := Ledger{}
+
+	b, err := ioutil.ReadFile(GetSaneFile("ledger.json"))
+	if err == nil {
+		err = json.Unmarshal(b, &ledger)
+		CheckWithMessage(err, "😕  Invalid ledger file!")
+	} else if !os.IsNotExist(err) {
+		Check(err)
+	}
+
+	if ledger.Entries == nil {
+		ledger.Entries = make(map[string]LedgerEntry)
+	}
+
+	return ledger
+}
+
+//WriteLedger write the apply ledger
+func WriteLedger(ledger Ledger) {
+	if DryRun {
+		return
+	}
+
+	b, err := json.MarshalIndent(ledger, "", "  ")
+	Check(err)
+
+	err = ioutil.WriteFile(GetSaneFile("ledger.json"), b, 0600)
+	Check(err)
+}
+
+func findLedgerFile(files []LedgerFile, dst string) (LedgerFile, bool) {
+	for _, f := range files {
+		if f.Destination == dst {
+			return f, true
+		}
+	}
+
+	return LedgerFile{}, false
+}
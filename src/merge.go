@@ -0,0 +1,342 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	//FormatYAML yaml config files
+	FormatYAML = "yaml"
+	//FormatTOML toml config files
+	FormatTOML = "toml"
+	//FormatINI ini config files (.gitconfig etc.)
+	FormatINI = "ini"
+)
+
+var mergeFormats = map[string]string{
+	".json":      FormatJSON,
+	".jsonc":     FormatJSON,
+	".yml":       FormatYAML,
+	".yaml":      FormatYAML,
+	".toml":      FormatTOML,
+	".ini":       FormatINI,
+	".cfg":       FormatINI,
+	".conf":      FormatINI,
+	".gitconfig": FormatINI,
+}
+
+//MergeChange a key sane set in a merged file. Old is the previous value in the format of the file if the key existed.
+type MergeChange struct {
+	Path    []string `json:"path"`
+	Existed bool     `json:"existed"`
+	Old     string   `json:"previous,omitempty"`
+	// the previous value as JSON, written by older versions
+	LegacyOld json.RawMessage `json:"old,omitempty"`
+}
+
+//detectMergeFormat get the format of a file to merge by its extension
+func detectMergeFormat(file string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == "" {
+		ext = strings.ToLower(filepath.Base(file))
+	}
+
+	if format, ok := mergeFormats[ext]; ok {
+		return format, nil
+	}
+
+	return "", errors.New("unknown format of " + file + ", set format: json|yaml|toml|ini")
+}
+
+//normalize converts yaml maps and json numbers into plain map[string]interface{}, int64 and float64 values
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for k, v := range val {
+			m[fmt.Sprintf("%v", k)] = normalize(v)
+		}
+		return m
+	case map[string]interface{}:
+		for k, v := range val {
+			val[k] = normalize(v)
+		}
+		return val
+	case []interface{}:
+		for i, v := range val {
+			val[i] = normalize(v)
+		}
+		return val
+	case []map[string]interface{}:
+		list := make([]interface{}, 0, len(val))
+		for _, v := range val {
+			list = append(list, normalize(v))
+		}
+		return list
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case int:
+		return int64(val)
+	}
+
+	return v
+}
+
+func decodeJSON(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+//tomlHasComments whether a TOML file has comments, which would get lost when it's written again
+func tomlHasComments(b []byte) bool {
+	var quote string
+
+	for i := 0; i < len(b); i++ {
+		switch {
+		case quote != "":
+			if b[i] == '\\' && quote[0] == '"' {
+				i++
+			} else if bytes.HasPrefix(b[i:], []byte(quote)) {
+				i += len(quote) - 1
+				quote = ""
+			}
+		case bytes.HasPrefix(b[i:], []byte(`"""`)), bytes.HasPrefix(b[i:], []byte("'''")):
+			quote = string(b[i : i+3])
+			i += 2
+		case b[i] == '"' || b[i] == '\'':
+			quote = string(b[i])
+		case b[i] == '#':
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseTOML(b []byte) (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+
+	if _, err := toml.Decode(string(b), &tree); err != nil {
+		return nil, err
+	}
+
+	return normalize(tree).(map[string]interface{}), nil
+}
+
+func serializeTOML(tree map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).Encode(tree)
+	return buf.Bytes(), err
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//mergeTree deep merges src into dst and records every key it sets
+func mergeTree(dst, src map[string]interface{}, prefix []string, changes *[]MergeChange) error {
+	for _, k := range sortedKeys(src) {
+		p := append(append([]string{}, prefix...), k)
+		sv := src[k]
+		dv, exists := dst[k]
+
+		sm, srcIsMap := sv.(map[string]interface{})
+		dm, dstIsMap := dv.(map[string]interface{})
+
+		if exists && srcIsMap && dstIsMap {
+			if err := mergeTree(dm, sm, p, changes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if exists && reflect.DeepEqual(dv, sv) {
+			continue
+		}
+
+		change := MergeChange{Path: p, Existed: exists}
+		if exists {
+			// kept as TOML so dates and times stay dates and times
+			old, err := serializeTOML(map[string]interface{}{"value": dv})
+			if err != nil {
+				return err
+			}
+			change.Old = string(old)
+		}
+
+		*changes = append(*changes, change)
+		dst[k] = sv
+	}
+
+	return nil
+}
+
+//unmergeTree undoes the recorded changes: added keys are deleted, overridden keys get their previous value back
+func unmergeTree(tree map[string]interface{}, changes []MergeChange) error {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		parent := tree
+
+		for _, k := range change.Path[:len(change.Path)-1] {
+			next, ok := parent[k].(map[string]interface{})
+			if !ok {
+				parent = nil
+				break
+			}
+			parent = next
+		}
+
+		if parent == nil {
+			// removed by the user in the meantime
+			continue
+		}
+
+		key := change.Path[len(change.Path)-1]
+
+		if !change.Existed {
+			delete(parent, key)
+			continue
+		}
+
+		old, err := parseTOML([]byte(change.Old))
+		if err != nil {
+			return err
+		}
+		parent[key] = old["value"]
+	}
+
+	return nil
+}
+
+//upgradeChanges convert previous values of older ledgers into the format of the file
+func upgradeChanges(changes []MergeChange, format string) ([]MergeChange, error) {
+	upgraded := make([]MergeChange, 0, len(changes))
+
+	for _, change := range changes {
+		if len(change.LegacyOld) != 0 {
+			switch format {
+			case FormatINI:
+				var lines []string
+				if err := json.Unmarshal(change.LegacyOld, &lines); err != nil {
+					return nil, err
+				}
+				change.Old = strings.Join(lines, "\n")
+			case FormatTOML:
+				var old interface{}
+				if err := decodeJSON(change.LegacyOld, &old); err != nil {
+					return nil, err
+				}
+				b, err := serializeTOML(map[string]interface{}{"value": normalize(old)})
+				if err != nil {
+					return nil, err
+				}
+				change.Old = string(b)
+			default:
+				// JSON is valid YAML as well
+				change.Old = string(change.LegacyOld)
+			}
+			change.LegacyOld = nil
+		}
+
+		upgraded = append(upgraded, change)
+	}
+
+	return upgraded, nil
+}
+
+//mergeContent merge content into the existing content of a destination. previous are the changes of the last apply which are undone first.
+func mergeContent(existing []byte, content []byte, format string, previous []MergeChange) ([]byte, []MergeChange, error) {
+	previous, err := upgradeChanges(previous, format)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch format {
+	case FormatJSON:
+		return mergeJSON(existing, content, previous)
+	case FormatYAML:
+		return mergeYAML(existing, content, previous)
+	case FormatINI:
+		return mergeINI(existing, content, previous)
+	case FormatTOML:
+	default:
+		return nil, nil, errors.New("unsupported format " + format)
+	}
+
+	if tomlHasComments(existing) {
+		return nil, nil, errors.New("toml files with comments can't be merged without losing them")
+	}
+
+	tree, err := parseTOML(existing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := unmergeTree(tree, previous); err != nil {
+		return nil, nil, err
+	}
+
+	src, err := parseTOML(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := make([]MergeChange, 0)
+	if err := mergeTree(tree, src, []string{}, &changes); err != nil {
+		return nil, nil, err
+	}
+
+	b, err := serializeTOML(tree)
+	return b, changes, err
+}
+
+//unmergeContent remove the changes sane made from a merged destination. Returns whether anything is left.
+func unmergeContent(existing []byte, format string, changes []MergeChange) ([]byte, bool, error) {
+	changes, err := upgradeChanges(changes, format)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch format {
+	case FormatJSON:
+		return unmergeJSONContent(existing, changes)
+	case FormatYAML:
+		return unmergeYAMLContent(existing, changes)
+	case FormatINI:
+		return unmergeINIContent(existing, changes)
+	case FormatTOML:
+	default:
+		return nil, false, errors.New("unsupported format " + format)
+	}
+
+	tree, err := parseTOML(existing)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := unmergeTree(tree, changes); err != nil {
+		return nil, false, err
+	}
+
+	b, err := serializeTOML(tree)
+	return b, len(tree) != 0, err
+}
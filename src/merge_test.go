@@ -0,0 +1,246 @@
+package src
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+//roundTrip merge content into existing, check the result and remove it again
+func roundTrip(t *testing.T, format string, existing string, content string, want string) {
+	t.Helper()
+
+	merged, changes, err := mergeContent([]byte(existing), []byte(content), format, nil)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+
+	if string(merged) != want {
+		t.Errorf("merged =\n%s\nwant\n%s", merged, want)
+	}
+
+	// applying again undoes the previous changes first and ends up with the same file
+	again, _, err := mergeContent(merged, []byte(content), format, changes)
+	if err != nil || string(again) != string(merged) {
+		t.Errorf("merged again =\n%s\n%v, want\n%s", again, err, merged)
+	}
+
+	restored, left, err := unmergeContent(merged, format, changes)
+	if err != nil {
+		t.Fatalf("unmerge: %v", err)
+	}
+
+	if string(restored) != existing {
+		t.Errorf("restored =\n%s\nwant\n%s", restored, existing)
+	}
+
+	if left != (strings.TrimSpace(existing) != "" && strings.TrimSpace(existing) != "{}") {
+		t.Errorf("left = %v for\n%s", left, restored)
+	}
+}
+
+func TestMergeJSONC(t *testing.T) {
+	existing := `// VS Code settings
+{
+    "editor.fontSize": 12, // too small
+    /* kept */
+    "files.exclude": {
+        "**/.git": true,
+    },
+    "zzz": [1, 2],
+}
+`
+	content := `{
+  "editor.fontSize": 14,
+  "files.exclude": {"**/node_modules": true},
+  "editor.rulers": [80, 120]
+}`
+	want := `// VS Code settings
+{
+    "editor.fontSize": 14, // too small
+    /* kept */
+    "files.exclude": {
+        "**/.git": true,
+        "**/node_modules": true,
+    },
+    "zzz": [1, 2],
+    "editor.rulers": [
+        80,
+        120
+    ],
+}
+`
+
+	roundTrip(t, FormatJSON, existing, content, want)
+}
+
+func TestMergeJSONEmpty(t *testing.T) {
+	merged, changes, err := mergeContent(nil, []byte(`{"a": {"b": "c"}}`), FormatJSON, nil)
+	if err != nil || string(merged) != "{\n  \"a\": {\n    \"b\": \"c\"\n  }\n}\n" {
+		t.Fatalf("merge into an empty file = %q, %v", merged, err)
+	}
+
+	restored, left, err := unmergeContent(merged, FormatJSON, changes)
+	if err != nil || left || strings.TrimSpace(string(restored)) != "{}" {
+		t.Errorf("unmerge = %q, %v, %v, want an empty object", restored, left, err)
+	}
+}
+
+func TestMergeJSONInvalid(t *testing.T) {
+	for _, existing := range []string{`[1, 2]`, `{"a": }`, `{"a": 1} trailing`, `{"a": "unterminated}`} {
+		if _, _, err := mergeContent([]byte(existing), []byte(`{"a": 2}`), FormatJSON, nil); err == nil {
+			t.Errorf("merging into %s didn't fail", existing)
+		}
+	}
+}
+
+func TestMergeYAML(t *testing.T) {
+	existing := `# kubectl aliases
+zeta: 1 # first
+alpha:
+    beta: old
+    list:
+        - a
+`
+	content := `alpha:
+  beta: new
+  gamma: true
+delta: [x, y]
+`
+	want := `# kubectl aliases
+zeta: 1 # first
+alpha:
+    beta: new
+    list:
+        - a
+    gamma: true
+delta: [x, y]
+`
+
+	roundTrip(t, FormatYAML, existing, content, want)
+}
+
+func TestMergeTOML(t *testing.T) {
+	existing := `released = 1979-05-27T07:32:00Z
+title = "old"
+`
+	content := `released = 2020-01-01T00:00:00Z
+[owner]
+name = "sane"
+`
+
+	merged, changes, err := mergeContent([]byte(existing), []byte(content), FormatTOML, nil)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+
+	restored, left, err := unmergeContent(merged, FormatTOML, changes)
+	if err != nil || !left {
+		t.Fatalf("unmerge = %v, %v", left, err)
+	}
+
+	var tree map[string]interface{}
+	if _, err := toml.Decode(string(restored), &tree); err != nil {
+		t.Fatal(err)
+	}
+
+	released, ok := tree["released"].(time.Time)
+	if !ok || !released.Equal(time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)) {
+		t.Errorf("released = %#v, want the original datetime", tree["released"])
+	}
+
+	if _, ok := tree["owner"]; ok || tree["title"] != "old" {
+		t.Errorf("restored =\n%s", restored)
+	}
+}
+
+func TestMergeTOMLComments(t *testing.T) {
+	if _, _, err := mergeContent([]byte("a = 1 # one\n"), []byte("b = 2\n"), FormatTOML, nil); err == nil {
+		t.Error("merging into a toml file with comments didn't fail")
+	}
+
+	existing := "a = \"# not a comment\"\nb = '''\n# neither\n'''\n"
+	if _, _, err := mergeContent([]byte(existing), []byte("c = 3\n"), FormatTOML, nil); err != nil {
+		t.Errorf("merging into a toml file with # in strings failed: %v", err)
+	}
+}
+
+func TestMergeINI(t *testing.T) {
+	existing := `# global settings
+[user]
+	name = Jane
+	email = jane@old.example.com ; work
+[remote "origin"]
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[core]
+	bare
+`
+	content := `[user]
+email = jane@example.com
+[alias]
+co = checkout
+[core]
+editor = vim
+`
+	want := `# global settings
+[user]
+	name = Jane
+	email = jane@example.com
+[remote "origin"]
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[core]
+	bare
+	editor = vim
+
+[alias]
+	co = checkout
+`
+
+	roundTrip(t, FormatINI, existing, content, want)
+}
+
+func TestUnmergeKeepsUserChanges(t *testing.T) {
+	existing := "{\n  \"a\": 1\n}\n"
+
+	merged, changes, err := mergeContent([]byte(existing), []byte(`{"b": 2}`), FormatJSON, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := strings.Replace(string(merged), `"a": 1`, `"a": 3`, 1)
+	restored, _, err := unmergeContent([]byte(edited), FormatJSON, changes)
+	if err != nil || string(restored) != "{\n  \"a\": 3\n}\n" {
+		t.Errorf("unmerge = %q, %v, want the user's change of a kept", restored, err)
+	}
+}
+
+func TestUnmergeLegacyChanges(t *testing.T) {
+	tests := []struct {
+		format   string
+		merged   string
+		old      string
+		restored string
+	}{
+		{FormatJSON, "{\n  \"a\": 2\n}\n", `{"b":[1,"c"]}`, "{\n  \"a\": {\"b\":[1,\"c\"]}\n}\n"},
+		{FormatYAML, "a: 2\n", `{"b":[1,"c"]}`, "a: {\"b\": [1, \"c\"]}\n"},
+		{FormatTOML, "a = 2\n", `"old"`, "a = \"old\"\n"},
+		{FormatINI, "[core]\n\ta = 2\n", `["\ta = 1","\ta = 3"]`, "[core]\n\ta = 1\n\ta = 3\n"},
+	}
+
+	for _, test := range tests {
+		path := []string{"a"}
+		if test.format == FormatINI {
+			path = []string{"core", "a"}
+		}
+
+		changes := []MergeChange{{Path: path, Existed: true, LegacyOld: []byte(test.old)}}
+		restored, _, err := unmergeContent([]byte(test.merged), test.format, changes)
+		if err != nil || string(restored) != test.restored {
+			t.Errorf("%s: unmerge = %q, %v, want %q", test.format, restored, err, test.restored)
+		}
+	}
+}
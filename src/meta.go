@@ -0,0 +1,67 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+//FileMeta permissions and ownership of a file
+type FileMeta struct {
+	Mode os.FileMode `json:"mode"`
+	UID  int         `json:"uid"`
+	GID  int         `json:"gid"`
+}
+
+//readMeta get the permissions and ownership of a file. Returns nil if it doesn't exist.
+func readMeta(file string) (*FileMeta, error) {
+	fi, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	uid, gid := fileOwner(fi)
+
+	return &FileMeta{Mode: fi.Mode().Perm(), UID: uid, GID: gid}, nil
+}
+
+//parseMode parse the mode of a file entry. Modes have to be quoted octal strings: yaml reads 0600 as octal but 600 as
+//decimal, and once parsed the two can't be told apart.
+func parseMode(v interface{}) (os.FileMode, error) {
+	var mode uint64
+
+	switch val := v.(type) {
+	case int:
+		return 0, fmt.Errorf("mode %v has to be quoted, e.g. mode: '0600'", v)
+	case string:
+		m, err := strconv.ParseUint(val, 8, 32)
+		if err != nil {
+			return 0, errors.New("invalid mode \"" + val + "\"")
+		}
+		mode = m
+	default:
+		return 0, fmt.Errorf("invalid mode %v", v)
+	}
+
+	if mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %v, use an octal number like 0600", v)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+//restoreMeta put back the permissions and ownership a file had before sane changed it
+func restoreMeta(tx *transaction, file string, meta *FileMeta) {
+	if meta == nil {
+		return
+	}
+
+	err := tx.chmod(file, meta.Mode)
+	tx.check(err, "❌  There was an error while restoring the permissions of "+file+"!")
+
+	err = tx.chown(file, meta.UID, meta.GID)
+	tx.check(err, "❌  There was an error while restoring the owner of "+file+"!")
+}
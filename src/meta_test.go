@@ -0,0 +1,47 @@
+package src
+
+import (
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		mode    os.FileMode
+		invalid bool
+	}{
+		// yaml reads this as decimal 400, which would be 0620
+		{yaml: "mode: 400", invalid: true},
+		// yaml reads this as octal, but it can't be told apart from a decimal 256
+		{yaml: "mode: 0400", invalid: true},
+		{yaml: "mode: '400'", mode: 0400},
+		{yaml: "mode: '0400'", mode: 0400},
+		{yaml: "mode: \"0755\"", mode: 0755},
+		{yaml: "mode: '0800'", invalid: true},
+		{yaml: "mode: '1777'", invalid: true},
+		{yaml: "mode: rw", invalid: true},
+	}
+
+	for _, test := range tests {
+		m := make(map[string]interface{})
+		if err := yaml.Unmarshal([]byte(test.yaml), &m); err != nil {
+			t.Fatal(err)
+		}
+
+		mode, err := parseMode(m["mode"])
+
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%s = %04o, want an error", test.yaml, mode)
+			}
+			continue
+		}
+
+		if err != nil || mode != test.mode {
+			t.Errorf("%s = %04o, %v, want %04o", test.yaml, mode, err, test.mode)
+		}
+	}
+}
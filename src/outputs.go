@@ -0,0 +1,150 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	//FormatShell print outputs as shell exports
+	FormatShell = "shell"
+	//FormatDotenv print outputs as a dotenv file
+	FormatDotenv = "dotenv"
+	//FormatJSON print outputs as a JSON object
+	FormatJSON = "json"
+)
+
+var outputNameExp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//OutputPair a rendered output of a running config
+type OutputPair struct {
+	Key   string
+	Value string
+}
+
+func findContainer(containers []DockerConfig, name string) (DockerConfig, error) {
+	for _, c := range containers {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+
+	return DockerConfig{}, errors.New("unknown container '" + name + "'")
+}
+
+func outputFuncs(containers []DockerConfig) template.FuncMap {
+	return template.FuncMap{
+		"port": func(name, target string) (string, error) {
+			c, err := findContainer(containers, name)
+			if err != nil {
+				return "", err
+			}
+
+			for _, port := range c.Ports {
+				if port.Target == target {
+					return port.Source, nil
+				}
+			}
+
+			return "", errors.New("container '" + name + "' doesn't publish port " + target)
+		},
+		"env": func(name, key string) (string, error) {
+			c, err := findContainer(containers, name)
+			if err != nil {
+				return "", err
+			}
+
+			for _, env := range c.Environment {
+				if env.Key == key {
+					return env.Value, nil
+				}
+			}
+
+			return "", errors.New("container '" + name + "' has no environment variable " + key)
+		},
+	}
+}
+
+func renderOutputs(m map[string]interface{}, instance Instance) []OutputPair {
+	outputs := make([]OutputPair, 0)
+
+	o, ok := m["outputs"]
+	if !ok {
+		return outputs
+	}
+
+	data := map[string]interface{}{
+		"Host": "localhost",
+	}
+
+	for k, v := range o.(map[interface{}]interface{}) {
+		key := k.(string)
+
+		if !outputNameExp.MatchString(key) {
+			CheckCouldntParse(errors.New(""), "Invalid output name \""+key+"\"!")
+		}
+
+		tpl, err := template.New(key).Funcs(outputFuncs(instance.Containers)).Parse(fmt.Sprintf("%v", v))
+		CheckCouldntParse(err, "Invalid output \""+key+"\"!")
+
+		var buf bytes.Buffer
+		if err = tpl.Execute(&buf, data); err != nil {
+			CheckCouldntParse(err, err.Error())
+		}
+
+		outputs = append(outputs, OutputPair{Key: key, Value: buf.String()})
+	}
+
+	sort.SliceStable(outputs, func(i, j int) bool {
+		return outputs[i].Key < outputs[j].Key
+	})
+
+	return outputs
+}
+
+//dotenvValue quote a value for a .env file. Only what dotenv parsers unescape is escaped, Go escapes like \u aren't understood.
+func dotenvValue(v string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`)
+	return `"` + replacer.Replace(v) + `"`
+}
+
+//PrintEnv print the outputs of a running config in the given format
+func PrintEnv(repo Repo, home string, format string) {
+	instance, ok := ReadState().Instances[GetRepoString(repo)]
+	if !ok {
+		fmt.Println("🤷  " + GetRepoString(repo) + " is not running!")
+		os.Exit(1)
+	}
+
+	outputs := renderOutputs(readSaneYml(repo, home), instance)
+
+	switch format {
+	case FormatShell:
+		for _, o := range outputs {
+			fmt.Println("export " + o.Key + "='" + strings.Replace(o.Value, "'", `'\''`, -1) + "'")
+		}
+	case FormatDotenv:
+		for _, o := range outputs {
+			fmt.Println(o.Key + "=" + dotenvValue(o.Value))
+		}
+	case FormatJSON:
+		j := make(map[string]string)
+		for _, o := range outputs {
+			j[o.Key] = o.Value
+		}
+
+		b, err := json.MarshalIndent(j, "", "  ")
+		Check(err)
+		fmt.Println(string(b))
+	default:
+		fmt.Println("❌  Unsupported format \"" + format + "\"!")
+		os.Exit(1)
+	}
+}
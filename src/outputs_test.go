@@ -0,0 +1,25 @@
+package src
+
+import (
+	"testing"
+)
+
+func TestDotenvValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"localhost:9092", `"localhost:9092"`},
+		{`p"a$s\w`, `"p\"a\$s\\w"`},
+		{"pässwörd😀", `"pässwörd😀"`},
+		{"tab\tand\x01", "\"tab\tand\x01\""},
+		{"two\nlines", `"two\nlines"`},
+		{"it's ${HOME}", `"it's \${HOME}"`},
+	}
+
+	for _, test := range tests {
+		if got := dotenvValue(test.value); got != test.want {
+			t.Errorf("dotenvValue(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
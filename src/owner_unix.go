@@ -0,0 +1,69 @@
+//go:build !windows
+// +build !windows
+
+package src
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+func fileOwner(fi os.FileInfo) (int, int) {
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Uid), int(stat.Gid)
+	}
+
+	return -1, -1
+}
+
+//linkCount the number of hard links of a file, 1 if it can't be read
+func linkCount(file string) int {
+	fi, err := os.Lstat(file)
+	if err != nil {
+		return 1
+	}
+
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Nlink)
+	}
+
+	return 1
+}
+
+//lookupOwner get the uid and gid of a user and group name or id. -1 means unchanged.
+func lookupOwner(owner string, group string) (int, int, error) {
+	uid, gid := -1, -1
+
+	if owner != "" {
+		id := owner
+		if _, err := strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return -1, -1, errors.New("unknown user " + owner)
+			}
+			id = u.Uid
+		}
+		uid, _ = strconv.Atoi(id)
+	}
+
+	if group != "" {
+		id := group
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, errors.New("unknown group " + group)
+			}
+			id = g.Gid
+		}
+		gid, _ = strconv.Atoi(id)
+	}
+
+	return uid, gid, nil
+}
+
+func setOwner(file string, uid int, gid int) error {
+	return os.Chown(file, uid, gid)
+}
@@ -0,0 +1,40 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func fileOwner(fi os.FileInfo) (int, int) {
+	return -1, -1
+}
+
+//linkCount the number of hard links of a file, 1 if it can't be read
+func linkCount(file string) int {
+	f, err := os.Open(file)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &info); err != nil {
+		return 1
+	}
+
+	return int(info.NumberOfLinks)
+}
+
+//lookupOwner files on windows don't have a uid and gid
+func lookupOwner(owner string, group string) (int, int, error) {
+	if owner != "" || group != "" {
+		return -1, -1, errors.New("owner and group aren't supported on windows")
+	}
+
+	return -1, -1, nil
+}
+
+func setOwner(file string, uid int, gid int) error {
+	return nil
+}
@@ -0,0 +1,272 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+//PackageManager a system package manager sane installs packages with
+type PackageManager interface {
+	Name() string
+	Available() bool
+	Installed(pkg string) bool
+	Install(pkgs []string) error
+	Remove(pkgs []string) error
+}
+
+//packageManagers the supported package managers in the order they are detected
+var packageManagers = []PackageManager{aptManager{}, dnfManager{}, pacmanManager{}, brewManager{}}
+
+//systemCmd a package manager command, elevated if it needs root and sane doesn't run as root
+func systemCmd(elevate bool, args ...string) *exec.Cmd {
+	if elevate && os.Geteuid() > 0 {
+		args = append(strings.Fields(Elevation), args...)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+type aptManager struct{}
+
+func (aptManager) Name() string { return "apt" }
+
+func (aptManager) Available() bool { return hasCommand("apt-get") }
+
+func (aptManager) Installed(pkg string) bool {
+	out, err := exec.Command("dpkg-query", "-W", "-f=${Status}", pkg).Output()
+	return err == nil && strings.HasSuffix(string(out), " installed")
+}
+
+func (aptManager) Install(pkgs []string) error {
+	return runCmd(systemCmd(true, append([]string{"apt-get", "install", "-y"}, pkgs...)...))
+}
+
+func (aptManager) Remove(pkgs []string) error {
+	return runCmd(systemCmd(true, append([]string{"apt-get", "remove", "-y"}, pkgs...)...))
+}
+
+type dnfManager struct{}
+
+func (dnfManager) Name() string { return "dnf" }
+
+func (dnfManager) Available() bool { return hasCommand("dnf") }
+
+func (dnfManager) Installed(pkg string) bool {
+	return exec.Command("rpm", "-q", pkg).Run() == nil
+}
+
+func (dnfManager) Install(pkgs []string) error {
+	return runCmd(systemCmd(true, append([]string{"dnf", "install", "-y"}, pkgs...)...))
+}
+
+func (dnfManager) Remove(pkgs []string) error {
+	return runCmd(systemCmd(true, append([]string{"dnf", "remove", "-y"}, pkgs...)...))
+}
+
+type pacmanManager struct{}
+
+func (pacmanManager) Name() string { return "pacman" }
+
+func (pacmanManager) Available() bool { return hasCommand("pacman") }
+
+func (pacmanManager) Installed(pkg string) bool {
+	return exec.Command("pacman", "-Q", pkg).Run() == nil
+}
+
+func (pacmanManager) Install(pkgs []string) error {
+	return runCmd(systemCmd(true, append([]string{"pacman", "-S", "--needed", "--noconfirm"}, pkgs...)...))
+}
+
+func (pacmanManager) Remove(pkgs []string) error {
+	return runCmd(systemCmd(true, append([]string{"pacman", "-R", "--noconfirm"}, pkgs...)...))
+}
+
+type brewManager struct{}
+
+func (brewManager) Name() string { return "brew" }
+
+func (brewManager) Available() bool { return hasCommand("brew") }
+
+func (brewManager) Installed(pkg string) bool {
+	return exec.Command("brew", "list", "--versions", pkg).Run() == nil
+}
+
+// brew refuses to run as root
+func (brewManager) Install(pkgs []string) error {
+	return runCmd(systemCmd(false, append([]string{"brew", "install"}, pkgs...)...))
+}
+
+func (brewManager) Remove(pkgs []string) error {
+	return runCmd(systemCmd(false, append([]string{"brew", "uninstall"}, pkgs...)...))
+}
+
+func findPackageManager(name string) (PackageManager, error) {
+	for _, manager := range packageManagers {
+		if manager.Name() == name {
+			return manager, nil
+		}
+	}
+
+	return nil, errors.New("unknown package manager " + name)
+}
+
+//extractPackages read the packages of a sanefile by package manager
+func extractPackages(m map[string]interface{}) map[string][]string {
+	packages := make(map[string][]string)
+
+	v, ok := m["packages"]
+	if !ok {
+		fmt.Println("❌  Packages not found!")
+		os.Exit(1)
+	}
+
+	for name, list := range v.(map[interface{}]interface{}) {
+		if _, err := findPackageManager(name.(string)); err != nil {
+			CheckCouldntParse(err, "Unsupported package manager \""+name.(string)+"\"!")
+		}
+
+		for _, pkg := range list.([]interface{}) {
+			packages[name.(string)] = append(packages[name.(string)], pkg.(string))
+		}
+	}
+
+	return packages
+}
+
+//detectPackageManager get the first available package manager the sanefile declares packages for
+func detectPackageManager(packages map[string][]string) PackageManager {
+	for _, manager := range packageManagers {
+		if _, ok := packages[manager.Name()]; ok && manager.Available() {
+			return manager
+		}
+	}
+
+	fmt.Println("❌  None of the package managers of the config is available!")
+	os.Exit(1)
+	return nil
+}
+
+//applyPackages install the missing packages of a config. The ledger records which packages sane installed per package manager.
+func applyPackages(m map[string]interface{}, entry LedgerEntry, tx *transaction) LedgerEntry {
+	packages := extractPackages(m)
+	manager := detectPackageManager(packages)
+	declared := packages[manager.Name()]
+
+	if entry.Packages == nil {
+		entry.Packages = make(map[string][]string)
+	}
+	owned := entry.Packages[manager.Name()]
+
+	installed := make([]string, 0)
+	missing := make([]string, 0)
+
+	for _, pkg := range declared {
+		if !manager.Installed(pkg) {
+			missing = append(missing, pkg)
+		} else if ContainsString(owned, pkg) {
+			// installed by sane on a previous apply
+			installed = append(installed, pkg)
+		}
+	}
+
+	dropped := make([]string, 0)
+	for _, pkg := range owned {
+		if !ContainsString(declared, pkg) && manager.Installed(pkg) {
+			dropped = append(dropped, pkg)
+		}
+	}
+
+	if len(missing) == 0 {
+		fmt.Println("👌  All packages are installed")
+	} else {
+		fmt.Println("📦  Installing " + strings.Join(missing, ", ") + " with " + manager.Name() + "...")
+
+		err := manager.Install(missing)
+		tx.check(err, "❌  There was an error while installing packages!")
+
+		if !DryRun {
+			tx.undo = append(tx.undo, func() error {
+				return manager.Remove(missing)
+			})
+		}
+
+		installed = append(installed, missing...)
+	}
+
+	if len(dropped) != 0 {
+		fmt.Println("📦  Removing " + strings.Join(dropped, ", ") + " with " + manager.Name() + "...")
+
+		err := manager.Remove(dropped)
+		tx.check(err, "❌  There was an error while removing packages!")
+
+		if !DryRun {
+			tx.undo = append(tx.undo, func() error {
+				return manager.Install(dropped)
+			})
+		}
+	}
+
+	// packages installed with another package manager stay recorded until the config is removed
+	entry.Packages[manager.Name()] = installed
+
+	return entry
+}
+
+//removePackages uninstall the packages sane installed for a config
+func removePackages(entry LedgerEntry, tx *transaction) {
+	names := make([]string, 0)
+	for name := range entry.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		manager, err := findPackageManager(name)
+		if err != nil {
+			fmt.Println("❌  " + err.Error())
+			os.Exit(1)
+		}
+
+		if !manager.Available() {
+			fmt.Println("⚠️  " + name + " isn't available anymore, leaving " + strings.Join(entry.Packages[name], ", ") + " installed")
+			continue
+		}
+
+		remove := make([]string, 0)
+		for _, pkg := range entry.Packages[name] {
+			if manager.Installed(pkg) {
+				remove = append(remove, pkg)
+			}
+		}
+
+		if len(remove) == 0 {
+			fmt.Println("👌  No " + name + " packages to remove")
+			continue
+		}
+
+		fmt.Println("📦  Removing " + strings.Join(remove, ", ") + " with " + name + "...")
+
+		err = manager.Remove(remove)
+		tx.check(err, "❌  There was an error while removing packages!")
+
+		if !DryRun {
+			tx.undo = append(tx.undo, func() error {
+				return manager.Install(remove)
+			})
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package src
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+//fakeManager a package manager which only keeps track of what's installed
+type fakeManager struct {
+	name      string
+	available bool
+	installed map[string]bool
+	installs  [][]string
+	removes   [][]string
+}
+
+func newFakeManager(name string, installed ...string) *fakeManager {
+	f := &fakeManager{name: name, available: true, installed: make(map[string]bool)}
+	for _, pkg := range installed {
+		f.installed[pkg] = true
+	}
+
+	return f
+}
+
+func (f *fakeManager) Name() string { return f.name }
+
+func (f *fakeManager) Available() bool { return f.available }
+
+func (f *fakeManager) Installed(pkg string) bool { return f.installed[pkg] }
+
+func (f *fakeManager) Install(pkgs []string) error {
+	f.installs = append(f.installs, pkgs)
+	for _, pkg := range pkgs {
+		f.installed[pkg] = true
+	}
+
+	return nil
+}
+
+func (f *fakeManager) Remove(pkgs []string) error {
+	f.removes = append(f.removes, pkgs)
+	for _, pkg := range pkgs {
+		delete(f.installed, pkg)
+	}
+
+	return nil
+}
+
+func (f *fakeManager) packages() []string {
+	pkgs := make([]string, 0)
+	for pkg := range f.installed {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	return pkgs
+}
+
+//useManagers swap the package managers for fakes. Returns a func which puts the real ones back.
+func useManagers(managers ...PackageManager) func() {
+	previous := packageManagers
+	packageManagers = managers
+
+	return func() { packageManagers = previous }
+}
+
+func packagesConfig(managers map[string][]string) map[string]interface{} {
+	packages := make(map[interface{}]interface{})
+	for name, pkgs := range managers {
+		list := make([]interface{}, 0)
+		for _, pkg := range pkgs {
+			list = append(list, pkg)
+		}
+		packages[name] = list
+	}
+
+	return map[string]interface{}{"mode": "packages", "packages": packages}
+}
+
+func TestApplyPackagesInstallsMissing(t *testing.T) {
+	apt := newFakeManager("apt", "git")
+	defer useManagers(apt)()
+
+	m := packagesConfig(map[string][]string{"apt": {"git", "htop", "jq"}})
+	entry := applyPackages(m, LedgerEntry{}, &transaction{})
+
+	if !reflect.DeepEqual(apt.installs, [][]string{{"htop", "jq"}}) {
+		t.Errorf("installs = %v, want [[htop jq]]", apt.installs)
+	}
+
+	// git was there before, so it isn't sane's
+	if got := entry.Packages["apt"]; !reflect.DeepEqual(got, []string{"htop", "jq"}) {
+		t.Errorf("recorded = %v, want [htop jq]", got)
+	}
+}
+
+func TestApplyPackagesSkipsInstalled(t *testing.T) {
+	apt := newFakeManager("apt", "git", "htop")
+	defer useManagers(apt)()
+
+	m := packagesConfig(map[string][]string{"apt": {"git", "htop"}})
+	entry := applyPackages(m, LedgerEntry{Packages: map[string][]string{"apt": {"htop"}}}, &transaction{})
+
+	if len(apt.installs) != 0 {
+		t.Errorf("installs = %v, want none", apt.installs)
+	}
+
+	if got := entry.Packages["apt"]; !reflect.DeepEqual(got, []string{"htop"}) {
+		t.Errorf("recorded = %v, want [htop]", got)
+	}
+}
+
+func TestApplyPackagesReinstallsRemoved(t *testing.T) {
+	apt := newFakeManager("apt")
+	defer useManagers(apt)()
+
+	// htop was installed by sane and uninstalled by the user since
+	m := packagesConfig(map[string][]string{"apt": {"htop"}})
+	entry := applyPackages(m, LedgerEntry{Packages: map[string][]string{"apt": {"htop"}}}, &transaction{})
+
+	if !reflect.DeepEqual(apt.installs, [][]string{{"htop"}}) {
+		t.Errorf("installs = %v, want [[htop]]", apt.installs)
+	}
+
+	if got := entry.Packages["apt"]; !reflect.DeepEqual(got, []string{"htop"}) {
+		t.Errorf("recorded = %v, want [htop]", got)
+	}
+}
+
+func TestApplyPackagesRemovesDropped(t *testing.T) {
+	apt := newFakeManager("apt", "git", "htop", "jq")
+	defer useManagers(apt)()
+
+	m := packagesConfig(map[string][]string{"apt": {"htop"}})
+	entry := applyPackages(m, LedgerEntry{Packages: map[string][]string{"apt": {"htop", "jq"}}}, &transaction{})
+
+	if !reflect.DeepEqual(apt.removes, [][]string{{"jq"}}) {
+		t.Errorf("removes = %v, want [[jq]]", apt.removes)
+	}
+
+	if got := apt.packages(); !reflect.DeepEqual(got, []string{"git", "htop"}) {
+		t.Errorf("installed = %v, want [git htop]", got)
+	}
+
+	if got := entry.Packages["apt"]; !reflect.DeepEqual(got, []string{"htop"}) {
+		t.Errorf("recorded = %v, want [htop]", got)
+	}
+}
+
+func TestApplyPackagesDetectsManager(t *testing.T) {
+	apt := newFakeManager("apt")
+	apt.available = false
+	brew := newFakeManager("brew")
+	defer useManagers(apt, brew)()
+
+	m := packagesConfig(map[string][]string{"apt": {"fd-find"}, "brew": {"fd"}})
+	entry := applyPackages(m, LedgerEntry{Packages: map[string][]string{"apt": {"ripgrep"}}}, &transaction{})
+
+	if len(apt.installs) != 0 || !reflect.DeepEqual(brew.installs, [][]string{{"fd"}}) {
+		t.Errorf("apt installs = %v, brew installs = %v, want only brew [[fd]]", apt.installs, brew.installs)
+	}
+
+	// the packages of the other manager are still sane's
+	if got := entry.Packages["apt"]; !reflect.DeepEqual(got, []string{"ripgrep"}) {
+		t.Errorf("recorded apt = %v, want [ripgrep]", got)
+	}
+}
+
+func TestRemovePackagesOnlyRemovesOwned(t *testing.T) {
+	apt := newFakeManager("apt", "git", "htop")
+	brew := newFakeManager("brew", "fd")
+	defer useManagers(apt, brew)()
+
+	entry := LedgerEntry{Packages: map[string][]string{"apt": {"htop", "jq"}, "brew": {"fd"}}}
+	removePackages(entry, &transaction{})
+
+	if got := apt.packages(); !reflect.DeepEqual(got, []string{"git"}) {
+		t.Errorf("apt installed = %v, want [git]", got)
+	}
+
+	// jq isn't installed anymore, so it isn't removed again
+	if !reflect.DeepEqual(apt.removes, [][]string{{"htop"}}) {
+		t.Errorf("apt removes = %v, want [[htop]]", apt.removes)
+	}
+
+	if got := brew.packages(); len(got) != 0 {
+		t.Errorf("brew installed = %v, want none", got)
+	}
+}
+
+func TestApplyPackagesRollback(t *testing.T) {
+	apt := newFakeManager("apt", "git")
+	defer useManagers(apt)()
+
+	tx := &transaction{}
+	applyPackages(packagesConfig(map[string][]string{"apt": {"git", "htop"}}), LedgerEntry{}, tx)
+	tx.rollback()
+
+	if got := apt.packages(); !reflect.DeepEqual(got, []string{"git"}) {
+		t.Errorf("installed after rollback = %v, want [git]", got)
+	}
+}
@@ -0,0 +1,143 @@
+package src
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//DryRun print the plan instead of changing anything
+var DryRun = false
+
+func plan(action string, msg string) {
+	fmt.Printf("📋  %-8s %s\n", action, msg)
+}
+
+func cmdString(cmd *exec.Cmd) string {
+	args := make([]string, 0, len(cmd.Args))
+
+	for _, arg := range cmd.Args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$") {
+			arg = strconv.Quote(arg)
+		}
+
+		args = append(args, arg)
+	}
+
+	return strings.Join(args, " ")
+}
+
+//runCmd run a command or print it in a dry run
+func runCmd(cmd *exec.Cmd) error {
+	if DryRun {
+		plan("run", cmdString(cmd))
+		return nil
+	}
+
+	return cmd.Run()
+}
+
+func makeDir(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+
+	if DryRun {
+		plan("mkdir", dir)
+		return nil
+	}
+
+	return fsFor(dir).MkdirAll(dir, 0755)
+}
+
+//removeEmptyDirs remove directories which don't contain anything (anymore), deepest first
+func removeEmptyDirs(dirs []string) {
+	sorted := append([]string{}, dirs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+
+	for _, dir := range sorted {
+		if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 0 {
+			continue
+		}
+
+		if DryRun {
+			plan("rmdir", dir)
+			continue
+		}
+
+		_ = fsFor(dir).Remove(dir)
+	}
+}
+
+func copyFile(src string, dst string) error {
+	if DryRun {
+		plan("copy", src+" → "+dst)
+		return nil
+	}
+
+	return fsFor(dst).Copy(src, dst)
+}
+
+func deleteFile(dst string) error {
+	if _, err := os.Lstat(dst); os.IsNotExist(err) {
+		return nil
+	}
+
+	if DryRun {
+		plan("delete", dst)
+		return nil
+	}
+
+	return fsFor(dst).Remove(dst)
+}
+
+func writeFile(dst string, content []byte) error {
+	if DryRun {
+		plan("write", dst)
+		return nil
+	}
+
+	return fsFor(dst).WriteFile(dst, content, 0644)
+}
+
+func chmodFile(dst string, mode os.FileMode) error {
+	if DryRun {
+		plan("chmod", fmt.Sprintf("%04o %s", mode, dst))
+		return nil
+	}
+
+	return fsFor(dst).Chmod(dst, mode)
+}
+
+func chownFile(dst string, uid int, gid int) error {
+	if DryRun {
+		plan("chown", fmt.Sprintf("%d:%d %s", uid, gid, dst))
+		return nil
+	}
+
+	return fsFor(dst).Chown(dst, uid, gid)
+}
+
+func symlinkFile(target string, dst string) error {
+	if DryRun {
+		plan("symlink", dst+" → "+target)
+		return nil
+	}
+
+	return fsFor(dst).Symlink(target, dst)
+}
+
+func hardlinkFile(target string, dst string) error {
+	if DryRun {
+		plan("hardlink", dst+" → "+target)
+		return nil
+	}
+
+	return fsFor(dst).Link(target, dst)
+}
@@ -0,0 +1,95 @@
+package src
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+//AutoPort host port placeholder that lets sane pick a free port (e.g. auto:9092)
+const AutoPort = "auto"
+
+func isPortFree(port string) bool {
+	l, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return false
+	}
+
+	_ = l.Close()
+	return true
+}
+
+func findFreePort(used map[string]string) string {
+	for {
+		l, err := net.Listen("tcp", ":0")
+		Check(err)
+
+		port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+		_ = l.Close()
+
+		if _, ok := used[port]; !ok {
+			return port
+		}
+	}
+}
+
+//allocatePorts checks explicit host ports for conflicts and assigns a free host port to every auto port
+func allocatePorts(configs []DockerConfig) {
+	used := make(map[string]string)
+
+	for _, dockerConfig := range configs {
+		for _, port := range dockerConfig.Ports {
+			if port.Source == AutoPort {
+				continue
+			}
+
+			if other, ok := used[port.Source]; ok {
+				fmt.Println("❌  Port " + port.Source + " is used by '" + other + "' and '" + dockerConfig.Name + "'!")
+				os.Exit(1)
+			}
+
+			if !isPortFree(port.Source) {
+				fmt.Println("❌  Port " + port.Source + " of container '" + dockerConfig.Name + "' is already in use!")
+				os.Exit(1)
+			}
+
+			used[port.Source] = dockerConfig.Name
+		}
+	}
+
+	for _, dockerConfig := range configs {
+		for i, port := range dockerConfig.Ports {
+			if port.Source == AutoPort {
+				dockerConfig.Ports[i].Source = findFreePort(used)
+				used[dockerConfig.Ports[i].Source] = dockerConfig.Name
+			}
+		}
+	}
+}
+
+func printEndpoints(configs []DockerConfig) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CONTAINER\tENDPOINT\tCONTAINER PORT")
+
+	for _, dockerConfig := range configs {
+		for _, port := range dockerConfig.Ports {
+			_, _ = fmt.Fprintln(w, dockerConfig.Name+"\tlocalhost:"+port.Source+"\t"+port.Target)
+		}
+	}
+
+	_ = w.Flush()
+}
+
+//PrintPorts print the endpoints of a running config
+func PrintPorts(repo Repo) {
+	state := ReadState()
+
+	if instance, ok := state.Instances[GetRepoString(repo)]; ok {
+		printEndpoints(instance.Containers)
+	} else {
+		fmt.Println("🤷  " + GetRepoString(repo) + " is not running!")
+		os.Exit(1)
+	}
+}
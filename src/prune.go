@@ -0,0 +1,285 @@
+package src
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	//ManagedLabel label of every docker resource created by sane
+	ManagedLabel = "sane.managed=true"
+	//ConfigLabel label containing the config a container belongs to
+	ConfigLabel = "sane.config"
+)
+
+var builtinNetworks = []string{"", "bridge", "host", "none", "default"}
+
+//dockerLines run a docker command and return the non empty lines of its output
+func dockerLines(args ...string) ([]string, error) {
+	b, err := exec.Command("docker", args...).Output()
+	if err != nil {
+		msg := "docker " + strings.Join(args, " ") + " failed"
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) != 0 {
+			msg += ": " + strings.TrimSpace(string(exitErr.Stderr))
+		}
+
+		return nil, errors.New(msg)
+	}
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+
+	return lines, nil
+}
+
+func imageExists(image string) bool {
+	return exec.Command("docker", "image", "inspect", image).Run() == nil
+}
+
+//ensureNetwork create a user defined network (labeled as managed by sane) if it doesn't exist yet
+func ensureNetwork(net string) {
+	if ContainsString(builtinNetworks, net) || strings.HasPrefix(net, "container:") {
+		return
+	}
+
+	if exec.Command("docker", "network", "inspect", net).Run() == nil {
+		return
+	}
+
+	fmt.Println("🕸  Creating network '" + net + "'...")
+	cmd := exec.Command("docker", "network", "create", "--label", ManagedLabel, net)
+	checkDebugCmd(cmd)
+	CheckWithMessage(runCmd(cmd), "❌  There was an error while creating the network '"+net+"'!")
+}
+
+//ensureVolume create a named volume (labeled as managed by sane) if it doesn't exist yet. Bind mounts are ignored.
+func ensureVolume(volume string) {
+	if strings.ContainsAny(volume, `/\`) || strings.HasPrefix(volume, ".") || strings.HasPrefix(volume, "~") {
+		return
+	}
+
+	if exec.Command("docker", "volume", "inspect", volume).Run() == nil {
+		return
+	}
+
+	cmd := exec.Command("docker", "volume", "create", "--label", ManagedLabel, volume)
+	checkDebugCmd(cmd)
+	CheckWithMessage(runCmd(cmd), "❌  There was an error while creating the volume '"+volume+"'!")
+}
+
+//PruneResources leftover docker resources created by sane
+type PruneResources struct {
+	Containers []string
+	Networks   []string
+	Volumes    []string
+	Images     []string
+}
+
+func findPruneResources(state SaneState) (PruneResources, error) {
+	res := PruneResources{
+		Containers: make([]string, 0),
+		Networks:   make([]string, 0),
+		Volumes:    make([]string, 0),
+		Images:     make([]string, 0),
+	}
+
+	containers, err := dockerLines("ps", "-a", "--filter", "label="+ManagedLabel, "--format", "{{.Names}}\t{{.State}}")
+	if err != nil {
+		return res, err
+	}
+
+	for _, line := range containers {
+		parts := strings.Split(line, "\t")
+
+		if len(parts) == 2 && parts[1] != "running" && parts[1] != "restarting" {
+			res.Containers = append(res.Containers, parts[0])
+		}
+	}
+
+	// a resource is only in use by containers which aren't pruned
+	inUse := func(args ...string) (bool, error) {
+		names, err := dockerLines(args...)
+		if err != nil {
+			return false, err
+		}
+
+		for _, c := range names {
+			if !ContainsString(res.Containers, c) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	networks, err := dockerLines("network", "ls", "--filter", "label="+ManagedLabel, "--format", "{{.Name}}")
+	if err != nil {
+		return res, err
+	}
+
+	for _, network := range networks {
+		used, err := inUse("network", "inspect", "-f", "{{range .Containers}}{{.Name}}\n{{end}}", network)
+		if err != nil {
+			return res, err
+		}
+
+		if !used {
+			res.Networks = append(res.Networks, network)
+		}
+	}
+
+	volumes, err := dockerLines("volume", "ls", "--filter", "label="+ManagedLabel, "--format", "{{.Name}}")
+	if err != nil {
+		return res, err
+	}
+
+	for _, volume := range volumes {
+		used, err := inUse("ps", "-a", "--filter", "volume="+volume, "--format", "{{.Names}}")
+		if err != nil {
+			return res, err
+		}
+
+		if !used {
+			res.Volumes = append(res.Volumes, volume)
+		}
+	}
+
+	for _, image := range state.Images {
+		if !imageExists(image) {
+			continue
+		}
+
+		used, err := inUse("ps", "-a", "--filter", "ancestor="+image, "--format", "{{.Names}}")
+		if err != nil {
+			return res, err
+		}
+
+		if !used {
+			res.Images = append(res.Images, image)
+		}
+	}
+
+	return res, nil
+}
+
+func printPruneResources(res PruneResources) {
+	for _, c := range res.Containers {
+		fmt.Println("  📦  container " + c)
+	}
+
+	for _, n := range res.Networks {
+		fmt.Println("  🕸  network   " + n)
+	}
+
+	for _, v := range res.Volumes {
+		fmt.Println("  💾  volume    " + v)
+	}
+
+	for _, i := range res.Images {
+		fmt.Println("  🖼  image     " + i)
+	}
+}
+
+func confirm(question string) bool {
+	fmt.Print(question + " [y/N] ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+func pruneResource(kind string, name string, args ...string) bool {
+	cmd := exec.Command("docker", args...)
+	checkDebugCmd(cmd)
+
+	if err := cmd.Run(); err != nil {
+		fmt.Println("❌  There was an error while removing the " + kind + " '" + name + "'!")
+		return false
+	}
+
+	return true
+}
+
+//Prune remove stopped containers, orphaned networks, unused volumes and images pulled by sane
+func Prune(dryRun bool, yes bool) {
+	state := ReadState()
+
+	// an incomplete list could prune resources which are still in use
+	res, err := findPruneResources(state)
+	if err != nil {
+		fmt.Println("❌  Couldn't list the docker resources, nothing was pruned: " + err.Error())
+		os.Exit(1)
+	}
+
+	if len(res.Containers)+len(res.Networks)+len(res.Volumes)+len(res.Images) == 0 {
+		fmt.Println("✨  Nothing to prune!")
+		return
+	}
+
+	fmt.Println("🧹  The following resources will be removed:")
+	printPruneResources(res)
+
+	if dryRun || (!yes && !confirm("Proceed?")) {
+		return
+	}
+
+	ok := true
+
+	for _, c := range res.Containers {
+		ok = pruneResource("container", c, "rm", c) && ok
+	}
+
+	for _, n := range res.Networks {
+		ok = pruneResource("network", n, "network", "rm", n) && ok
+	}
+
+	for _, v := range res.Volumes {
+		ok = pruneResource("volume", v, "volume", "rm", v) && ok
+	}
+
+	for _, i := range res.Images {
+		ok = pruneResource("image", i, "rmi", i) && ok
+	}
+
+	for key, instance := range state.Instances {
+		remaining := make([]DockerConfig, 0)
+
+		for _, c := range instance.Containers {
+			if !ContainsString(res.Containers, c.Name) {
+				remaining = append(remaining, c)
+			}
+		}
+
+		if len(remaining) == 0 {
+			delete(state.Instances, key)
+		} else {
+			instance.Containers = remaining
+			state.Instances[key] = instance
+		}
+	}
+
+	images := make([]string, 0)
+	for _, image := range state.Images {
+		if imageExists(image) {
+			images = append(images, image)
+		}
+	}
+	state.Images = images
+
+	WriteState(state)
+
+	if !ok {
+		os.Exit(1)
+	}
+
+	fmt.Println("✨  Pruned leftover resources!")
+}
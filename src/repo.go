@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"path"
 	"regexp"
+	"strings"
 )
 
 //TopicMap a list of topics and corresponding emojis
@@ -68,6 +69,21 @@ func GetRepoFromString(configString string) Repo {
 	}
 }
 
+//GetRepoString get the string representation of a repo (inverse of GetRepoFromString)
+func GetRepoString(repo Repo) string {
+	branch := ""
+
+	if repo.Branch == "" {
+		if repo.Tag != "" {
+			branch = "@" + repo.Tag
+		}
+	} else {
+		branch = "/" + repo.Branch
+	}
+
+	return repo.User + "/" + repo.Name + branch
+}
+
 //GetTopicEmojis get emoji representation of Repo topics
 func GetTopicEmojis(topics []string) string {
 	topicstr := ""
@@ -151,6 +167,16 @@ func GetRepoFolder(repo Repo) string {
 	return target
 }
 
+//GetRepoCommit get the commit a pulled config is checked out at
+func GetRepoCommit(repo Repo, home string) string {
+	b, err := exec.Command("git", "-C", path.Join(home, GetRepoFolder(repo)), "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
 //PurgeRepo purge a repo
 func PurgeRepo(repo Repo, home string, cfg SaneConfig) SaneConfig {
 	target := path.Join(home, GetRepoFolder(repo))
@@ -166,9 +192,16 @@ func PurgeRepo(repo Repo, home string, cfg SaneConfig) SaneConfig {
 	return cfg
 }
 
-//AutoPullRepo pulls if not exists
+//AutoPullRepo pulls if not exists, in a dry run it only plans the pull
 func AutoPullRepo(cfg SaneConfig, repo Repo, home string) SaneConfig {
 	if !Contains(cfg.Repos, repo) {
+		if DryRun {
+			// there is no sanefile to plan anything else with before the config is pulled
+			plan("clone", "https://github.com/"+repo.User+"/"+repo.Name+".git")
+			fmt.Println("🤷‍  Config missing, it would be pulled automatically")
+			os.Exit(0)
+		}
+
 		fmt.Println("🤷‍  Config missing, pulling automatically...")
 		cfg = PullRepo(repo, home, cfg)
 	}
@@ -0,0 +1,184 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	//START start constant
+	START = "start"
+	//STOP stop constant
+	STOP = "stop"
+)
+
+var scriptActions = []string{APPLY, REMOVE, START, STOP}
+
+//Script a script of the repo run for an action of a config
+type Script struct {
+	File        string `json:"file"`
+	Interpreter string `json:"interpreter"`
+}
+
+//extractScripts read the scripts of a sanefile. A script is a file or an object with file and interpreter.
+func extractScripts(m map[string]interface{}) map[string]Script {
+	scripts := make(map[string]Script)
+
+	interpreter, _ := m["interpreter"].(string)
+
+	v, ok := m["scripts"]
+	if !ok {
+		fmt.Println("❌  Scripts not found!")
+		os.Exit(1)
+	}
+
+	for action, s := range v.(map[interface{}]interface{}) {
+		if !ContainsString(scriptActions, action.(string)) {
+			CheckCouldntParse(errors.New(""), "Unknown script action \""+action.(string)+"\"!")
+		}
+
+		script := Script{Interpreter: interpreter}
+
+		switch val := s.(type) {
+		case string:
+			script.File = val
+		case map[interface{}]interface{}:
+			script.File, _ = val["file"].(string)
+
+			if i, ok := val["interpreter"]; ok {
+				script.Interpreter = i.(string)
+			}
+		}
+
+		if script.File == "" {
+			CheckCouldntParse(errors.New(""), "Script without a file for "+action.(string)+"!")
+		}
+
+		scripts[action.(string)] = script
+	}
+
+	return scripts
+}
+
+func logDir(repo Repo) string {
+	return GetSaneFile(filepath.Join("logs", strings.TrimPrefix(GetRepoFolder(repo), "./")))
+}
+
+//scriptEnv the environment of a script: the repo folder and the user's variables as SANE_VAR_<NAME>
+func scriptEnv(repo Repo, action string, vars map[string]string) []string {
+	env := append(os.Environ(),
+		"SANE_CONFIG="+GetRepoString(repo),
+		"SANE_REPO_DIR="+GetSaneFile(GetRepoFolder(repo)),
+		"SANE_ACTION="+action,
+		"SANE_HOME="+GetSaneFile(""),
+	)
+
+	for k, v := range vars {
+		env = append(env, "SANE_VAR_"+strings.ToUpper(k)+"="+v)
+	}
+
+	return env
+}
+
+//runScript run the script of an action from the repo folder. The output is also written to ~/.sane/logs. Returns the log file.
+func runScript(repo Repo, action string, script Script, vars map[string]string) (string, error) {
+	folder := GetSaneFile(GetRepoFolder(repo))
+	file := filepath.Join(folder, script.File)
+
+	if _, err := os.Stat(file); err != nil {
+		return "", errors.New("script " + script.File + " not found")
+	}
+
+	args := append(strings.Fields(script.Interpreter), file)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = folder
+	cmd.Env = scriptEnv(repo, action, vars)
+	cmd.Stdin = os.Stdin
+
+	if DryRun {
+		return "", runCmd(cmd)
+	}
+
+	if err := os.MkdirAll(logDir(repo), 0700); err != nil {
+		return "", err
+	}
+
+	logFile := filepath.Join(logDir(repo), time.Now().Format("20060102-150405")+"-"+action+".log")
+
+	log, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer log.Close()
+
+	cmd.Stdout = io.MultiWriter(os.Stdout, log)
+	cmd.Stderr = io.MultiWriter(os.Stderr, log)
+
+	fmt.Println("📜  Running " + action + " script " + script.File + "...")
+	return logFile, cmd.Run()
+}
+
+//checkScript run the script of an action if the config has one and exit if it fails
+func checkScript(repo Repo, action string, scripts map[string]Script, vars map[string]string) string {
+	script, ok := scripts[action]
+	if !ok {
+		fmt.Println("🤷  " + GetRepoString(repo) + " has no " + action + " script")
+		return ""
+	}
+
+	logFile, err := runScript(repo, action, script, vars)
+	if err != nil {
+		msg := "❌  The " + action + " script failed: " + err.Error()
+		if logFile != "" {
+			msg += " (see " + logFile + ")"
+		}
+
+		fmt.Println(msg)
+		os.Exit(1)
+	}
+
+	return logFile
+}
+
+func applyScript(m map[string]interface{}, repo Repo, cfg SaneConfig, entry LedgerEntry) LedgerEntry {
+	entry.Scripts = extractScripts(m)
+	// the remove script gets the same variables, even if the sanefile changes in the meantime
+	entry.Vars = templateData(m, cfg).Vars
+	entry.Log = checkScript(repo, APPLY, entry.Scripts, entry.Vars)
+
+	return entry
+}
+
+//removeVars the variables a config was applied with. Entries of older versions don't record them, so they are read from the sanefile.
+func removeVars(repo Repo, home string, cfg SaneConfig, entry LedgerEntry) map[string]string {
+	if entry.Vars != nil {
+		return entry.Vars
+	}
+
+	var m map[string]interface{}
+	if _, err := os.Stat(filepath.Join(home, GetRepoFolder(repo), "sane.yml")); err == nil {
+		m = readSaneYml(repo, home)
+	}
+
+	return templateData(m, cfg).Vars
+}
+
+//runScriptAction run the start or stop script of a config in script mode with its hooks
+func runScriptAction(m map[string]interface{}, repo Repo, action string) {
+	hooks := extractHooks(m)
+	pre, post := PreStart, PostStart
+
+	if action == STOP {
+		pre, post = PreStop, PostStop
+	}
+
+	checkHooks(hooks, pre, repo)
+	checkScript(repo, action, extractScripts(m), templateData(m, ReadConfig()).Vars)
+	checkHooks(hooks, post, repo)
+}
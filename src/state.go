@@ -0,0 +1,54 @@
+package src
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+//Instance a running sane stack
+type Instance struct {
+	Repo       Repo              `json:"repo"`
+	Containers []DockerConfig    `json:"containers"`
+	Hooks      map[string][]Hook `json:"hooks,omitempty"`
+	Started    time.Time         `json:"started"`
+}
+
+//SaneState runtime state of sane (running stacks etc.)
+type SaneState struct {
+	Instances map[string]Instance `json:"instances"`
+	Images    []string            `json:"images"`
+}
+
+//ReadState read the runtime state. Returns an empty state if none was written yet.
+func ReadState() SaneState {
+	state := SaneState{}
+
+	b, err := ioutil.ReadFile(GetSaneFile("state.json"))
+	if err == nil {
+		err = json.Unmarshal(b, &state)
+		CheckWithMessage(err, "😕  Invalid state file!")
+	} else if !os.IsNotExist(err) {
+		Check(err)
+	}
+
+	if state.Instances == nil {
+		state.Instances = make(map[string]Instance)
+	}
+
+	return state
+}
+
+//WriteState write the runtime state
+func WriteState(state SaneState) {
+	if DryRun {
+		return
+	}
+
+	b, err := json.Marshal(state)
+	Check(err)
+
+	err = ioutil.WriteFile(GetSaneFile("state.json"), b, 0600)
+	Check(err)
+}
@@ -0,0 +1,123 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+//TemplateData the data templated config files are rendered with
+type TemplateData struct {
+	OS       string
+	Arch     string
+	Distro   string
+	Hostname string
+	Home     string
+	User     string
+	Env      map[string]string
+	Vars     map[string]string
+}
+
+//linuxDistro get the ID of the distribution from /etc/os-release (e.g. ubuntu, fedora, arch)
+func linuxDistro() string {
+	b, err := ioutil.ReadFile("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "ID=") {
+			return strings.Trim(strings.TrimPrefix(line, "ID="), "\"'")
+		}
+	}
+
+	return ""
+}
+
+//templateData collect host facts, the environment and the variables (sanefile defaults overridden by the user's variables)
+func templateData(m map[string]interface{}, cfg SaneConfig) TemplateData {
+	data := TemplateData{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		Env:  make(map[string]string),
+		Vars: make(map[string]string),
+	}
+
+	if runtime.GOOS == "linux" {
+		data.Distro = linuxDistro()
+	}
+
+	data.Hostname, _ = os.Hostname()
+	data.Home, _ = homedir.Dir()
+
+	if u, err := user.Current(); err == nil {
+		data.User = u.Username
+	}
+
+	for _, env := range os.Environ() {
+		if i := strings.Index(env, "="); i > 0 {
+			data.Env[env[:i]] = env[i+1:]
+		}
+	}
+
+	if vars, ok := m["variables"]; ok {
+		for k, v := range vars.(map[interface{}]interface{}) {
+			data.Vars[fmt.Sprintf("%v", k)] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	for k, v := range cfg.Variables {
+		data.Vars[k] = v
+	}
+
+	return data
+}
+
+//renderTemplate render a file of the repo folder with Go's text/template
+func renderTemplate(file string, data TemplateData) ([]byte, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl, err := template.New(filepath.Base(file)).Option("missingkey=error").Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+//evalCondition evaluate the when condition of a file entry, e.g. eq .OS "linux". Missing keys are empty.
+func evalCondition(expr string, data TemplateData) (bool, error) {
+	expr = strings.TrimSpace(expr)
+
+	if strings.HasPrefix(expr, "{{") && strings.HasSuffix(expr, "}}") {
+		expr = strings.TrimSpace(expr[2 : len(expr)-2])
+	}
+
+	tpl, err := template.New("when").Option("missingkey=zero").Parse("{{ if " + expr + " }}true{{ end }}")
+	if err != nil {
+		return false, err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return false, err
+	}
+
+	return buf.String() == "true", nil
+}
@@ -0,0 +1,223 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+//transaction file changes which are rolled back if any of them fails
+type transaction struct {
+	undo []func() error
+	// directories created by the transaction
+	created []string
+}
+
+//check rolls back every change of the transaction and exits if err isn't nil
+func (tx *transaction) check(err error, msg string) {
+	if err != nil {
+		fmt.Println(msg)
+		tx.rollback()
+		os.Exit(1)
+	}
+}
+
+func (tx *transaction) changed() bool {
+	return len(tx.undo) != 0
+}
+
+func (tx *transaction) rollback() {
+	if len(tx.undo) == 0 {
+		return
+	}
+
+	fmt.Println("⏪  Rolling back...")
+
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		if err := tx.undo[i](); err != nil {
+			fmt.Println("❌  Couldn't roll back: " + err.Error())
+		}
+	}
+
+	tx.undo = nil
+}
+
+//stash remembers the current state of dst so it can be put back on rollback
+func (tx *transaction) stash(dst string) error {
+	if DryRun {
+		return nil
+	}
+
+	fi, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		tx.undo = append(tx.undo, func() error {
+			err := fsFor(dst).Remove(dst)
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		})
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(dst)
+		if err != nil {
+			return err
+		}
+
+		tx.undo = append(tx.undo, func() error {
+			_ = fsFor(dst).Remove(dst)
+			return fsFor(dst).Symlink(link, dst)
+		})
+		return nil
+	}
+
+	b, err := readDestination(dst)
+	if err != nil {
+		return err
+	}
+
+	tx.undo = append(tx.undo, func() error {
+		// dst might have been replaced by a link in the meantime
+		_ = fsFor(dst).Remove(dst)
+		if err := fsFor(dst).WriteFile(dst, b, fi.Mode().Perm()); err != nil {
+			return err
+		}
+
+		// WriteFile is subject to the umask
+		return fsFor(dst).Chmod(dst, fi.Mode().Perm())
+	})
+	return nil
+}
+
+func (tx *transaction) makeDir(dir string) error {
+	missing := make([]string, 0)
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || d == filepath.Dir(d) {
+			break
+		}
+		missing = append(missing, d)
+	}
+
+	if err := makeDir(dir); err != nil {
+		return err
+	}
+
+	tx.created = append(tx.created, missing...)
+
+	if !DryRun && len(missing) != 0 {
+		tx.undo = append(tx.undo, func() error {
+			for _, d := range missing {
+				_ = fsFor(d).Remove(d)
+			}
+			return nil
+		})
+	}
+
+	return nil
+}
+
+//replace stash dst before it's overwritten. Links are removed so they aren't written through,
+//a hard link would otherwise overwrite the file in the repo.
+func (tx *transaction) replace(dst string) error {
+	if IsSymlink(dst) || linkCount(dst) > 1 {
+		return tx.delete(dst)
+	}
+
+	return tx.stash(dst)
+}
+
+func (tx *transaction) copy(src string, dst string) error {
+	if err := tx.replace(dst); err != nil {
+		return err
+	}
+
+	return copyFile(src, dst)
+}
+
+func (tx *transaction) delete(dst string) error {
+	if err := tx.stash(dst); err != nil {
+		return err
+	}
+
+	return deleteFile(dst)
+}
+
+func (tx *transaction) write(dst string, content []byte) error {
+	if err := tx.replace(dst); err != nil {
+		return err
+	}
+
+	return writeFile(dst, content)
+}
+
+//chmod set the permissions of dst. The previous ones are put back on rollback.
+func (tx *transaction) chmod(dst string, mode os.FileMode) error {
+	meta, err := readMeta(dst)
+	if err != nil {
+		return err
+	}
+
+	if meta != nil && meta.Mode == mode {
+		return nil
+	}
+
+	if err := chmodFile(dst, mode); err != nil {
+		return err
+	}
+
+	if !DryRun {
+		tx.undo = append(tx.undo, func() error {
+			return fsFor(dst).Chmod(dst, meta.Mode)
+		})
+	}
+
+	return nil
+}
+
+//chown set the owner of dst, -1 leaves the uid or gid unchanged. The previous owner is put back on rollback.
+func (tx *transaction) chown(dst string, uid int, gid int) error {
+	if uid < 0 && gid < 0 {
+		return nil
+	}
+
+	meta, err := readMeta(dst)
+	if err != nil {
+		return err
+	}
+
+	if meta != nil && (uid < 0 || uid == meta.UID) && (gid < 0 || gid == meta.GID) {
+		return nil
+	}
+
+	if err := chownFile(dst, uid, gid); err != nil {
+		return err
+	}
+
+	if !DryRun {
+		tx.undo = append(tx.undo, func() error {
+			return fsFor(dst).Chown(dst, meta.UID, meta.GID)
+		})
+	}
+
+	return nil
+}
+
+func (tx *transaction) symlink(target string, dst string) error {
+	if err := tx.delete(dst); err != nil {
+		return err
+	}
+
+	return symlinkFile(target, dst)
+}
+
+func (tx *transaction) hardlink(target string, dst string) error {
+	if err := tx.delete(dst); err != nil {
+		return err
+	}
+
+	return hardlinkFile(target, dst)
+}
@@ -0,0 +1,226 @@
+package src
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+//snapshotDir read every file, link and directory below dir by its relative path
+func snapshotDir(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+
+	err := filepath.Walk(dir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil || file == dir {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, file)
+
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(file)
+			files[rel] = "-> " + link
+			return err
+		case fi.IsDir():
+			files[rel] = "dir"
+		default:
+			b, err := ioutil.ReadFile(file)
+			files[rel] = fi.Mode().Perm().String() + " " + string(b)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func TestTransactionRollback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("links need privileges on windows")
+	}
+
+	tests := []struct {
+		name string
+		// run the changes of the transaction in dir, the repo file is dir/repo
+		run func(tx *transaction, dir string) error
+		// files after the changes, nil if they aren't checked
+		changed map[string]string
+	}{
+		{"write an existing file", func(tx *transaction, dir string) error {
+			return tx.write(filepath.Join(dir, "a"), []byte("new"))
+		}, nil},
+		{"write a new file", func(tx *transaction, dir string) error {
+			return tx.write(filepath.Join(dir, "new"), []byte("new"))
+		}, nil},
+		{"delete", func(tx *transaction, dir string) error {
+			return tx.delete(filepath.Join(dir, "a"))
+		}, nil},
+		{"copy over a symlink", func(tx *transaction, dir string) error {
+			return tx.copy(filepath.Join(dir, "a"), filepath.Join(dir, "link"))
+		}, nil},
+		{"write over a hard link", func(tx *transaction, dir string) error {
+			return tx.write(filepath.Join(dir, "hard"), []byte("new"))
+		}, map[string]string{
+			"a":    "-rw-r--r-- a",
+			"link": "-> a",
+			"repo": "-rw-r--r-- repo",
+			"hard": "-rw-r--r-- new",
+		}},
+		{"symlink over a file", func(tx *transaction, dir string) error {
+			return tx.symlink(filepath.Join(dir, "repo"), filepath.Join(dir, "a"))
+		}, nil},
+		{"create directories", func(tx *transaction, dir string) error {
+			if err := tx.makeDir(filepath.Join(dir, "x", "y")); err != nil {
+				return err
+			}
+			return tx.write(filepath.Join(dir, "x", "y", "z"), []byte("z"))
+		}, nil},
+		{"chmod", func(tx *transaction, dir string) error {
+			return tx.chmod(filepath.Join(dir, "a"), 0600)
+		}, map[string]string{
+			"a":    "-rw------- a",
+			"link": "-> a",
+			"repo": "-rw-r--r-- repo",
+			"hard": "-rw-r--r-- repo",
+		}},
+		{"several changes of the same file", func(tx *transaction, dir string) error {
+			a := filepath.Join(dir, "a")
+			if err := tx.write(a, []byte("first")); err != nil {
+				return err
+			}
+			if err := tx.chmod(a, 0700); err != nil {
+				return err
+			}
+			return tx.symlink(filepath.Join(dir, "repo"), a)
+		}, nil},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "sane-tx-")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, content := range map[string]string{"a": "a", "repo": "repo"} {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_ = os.Chmod(filepath.Join(dir, name), 0644)
+		}
+		if err := os.Symlink("a", filepath.Join(dir, "link")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Link(filepath.Join(dir, "repo"), filepath.Join(dir, "hard")); err != nil {
+			t.Fatal(err)
+		}
+
+		before := snapshotDir(t, dir)
+		tx := &transaction{}
+
+		if err := test.run(tx, dir); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+
+		if !tx.changed() {
+			t.Errorf("%s: the transaction has nothing to undo", test.name)
+		}
+
+		if test.changed != nil {
+			after := snapshotDir(t, dir)
+			for file, want := range test.changed {
+				if after[file] != want {
+					t.Errorf("%s: %s = %q before the rollback, want %q", test.name, file, after[file], want)
+				}
+			}
+		}
+
+		tx.rollback()
+
+		after := snapshotDir(t, dir)
+		if len(after) != len(before) {
+			t.Errorf("%s: %v after the rollback, want %v", test.name, after, before)
+		}
+
+		for file, want := range before {
+			if after[file] != want {
+				t.Errorf("%s: %s = %q after the rollback, want %q", test.name, file, after[file], want)
+			}
+		}
+
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestTransactionUndoAfterFailedWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sane-tx-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a")
+	if err := ioutil.WriteFile(a, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := &transaction{}
+
+	if err := tx.write(a, []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.write(filepath.Join(dir, "b"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the parent directory is missing
+	if err := tx.write(filepath.Join(dir, "missing", "c"), []byte("c")); err == nil {
+		t.Fatal("writing into a missing directory didn't fail")
+	}
+
+	tx.rollback()
+
+	if b, err := ioutil.ReadFile(a); err != nil || string(b) != "a" {
+		t.Errorf("a = %q, %v after the rollback, want \"a\"", b, err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("%d files after the rollback, want only a", len(files))
+	}
+
+	if tx.changed() {
+		t.Error("the transaction still has changes to undo after the rollback")
+	}
+}
+
+func TestTransactionDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sane-tx-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	DryRun = true
+	defer func() { DryRun = false }()
+
+	tx := &transaction{}
+	a := filepath.Join(dir, "a")
+
+	if err := tx.makeDir(filepath.Join(dir, "x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.write(a, []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 || tx.changed() {
+		t.Errorf("a dry run changed %d files and has %d changes to undo", len(files), len(tx.undo))
+	}
+}
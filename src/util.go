@@ -1,9 +1,14 @@
 package src
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 )
 
 //Check Checks if an error is nil. Prints the error and exits if it isnt't.
@@ -29,6 +34,64 @@ func CheckCouldntParse(err error, info string) {
 	}
 }
 
+//FileHash get the sha256 hash of a file's content
+func FileHash(file string) (string, error) {
+	b, err := readDestination(file)
+	if err != nil {
+		return "", err
+	}
+
+	return ContentHash(b), nil
+}
+
+//ContentHash get the sha256 hash of some content
+func ContentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+//SameContent check if two files exist and have the same content
+func SameContent(a, b string) bool {
+	contentA, err := ioutil.ReadFile(a)
+	if err != nil {
+		return false
+	}
+
+	contentB, err := readDestination(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(contentA, contentB)
+}
+
+//HasContent check if a file exists and has the given content
+func HasContent(file string, content []byte) bool {
+	b, err := ioutil.ReadFile(file)
+	return err == nil && bytes.Equal(b, content)
+}
+
+//SameFile check if two paths point to the same file (e.g. hardlinks)
+func SameFile(a, b string) bool {
+	infoA, err := os.Lstat(a)
+	if err != nil {
+		return false
+	}
+
+	infoB, err := os.Lstat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(infoA, infoB)
+}
+
+//IsSymlink check if a path is a symbolic link
+func IsSymlink(file string) bool {
+	fi, err := os.Lstat(file)
+	return err == nil && fi.Mode()&os.ModeSymlink != 0
+}
+
 //Contains check if array contains repo
 func Contains(arr []Repo, item Repo) bool {
 	for _, a := range arr {
@@ -49,6 +112,16 @@ func IndexOf(arr []Repo, item Repo) int {
 	return -1
 }
 
+//ContainsString check if array contains string
+func ContainsString(arr []string, item string) bool {
+	for _, a := range arr {
+		if a == item {
+			return true
+		}
+	}
+	return false
+}
+
 //Mapkeys get keys by value
 func Mapkeys(m map[string]string, value string) []string {
 	var keys []string
@@ -60,3 +133,39 @@ func Mapkeys(m map[string]string, value string) []string {
 
 	return keys
 }
+
+//ExtractFlag removes a flag from the args. Returns the remaining args and whether the flag was set.
+func ExtractFlag(args []string, flag string) ([]string, bool) {
+	rest := make([]string, 0, len(args))
+	found := false
+
+	for _, arg := range args {
+		if arg == flag {
+			found = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+
+	return rest, found
+}
+
+//ExtractFlagValue removes a flag and its value (--flag value or --flag=value) from the args. Returns the remaining args and the value or def if the flag wasn't set.
+func ExtractFlagValue(args []string, flag string, def string) ([]string, string) {
+	rest := make([]string, 0, len(args))
+	value := def
+
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == flag && i+1 < len(args):
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], flag+"="):
+			value = strings.TrimPrefix(args[i], flag+"=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+
+	return rest, value
+}
@@ -0,0 +1,208 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// YAML files are merged as yaml.v3 nodes, which keep comments and the order of keys.
+
+func parseYAMLDocument(b []byte) (*yamlv3.Node, error) {
+	doc := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal(b, doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Kind == 0 {
+		doc.Kind = yamlv3.DocumentNode
+	}
+
+	if len(doc.Content) == 0 {
+		doc.Content = []*yamlv3.Node{{Kind: yamlv3.MappingNode, Tag: "!!map"}}
+	}
+
+	if doc.Content[0].Kind != yamlv3.MappingNode {
+		return nil, errors.New("only yaml mappings can be merged")
+	}
+
+	return doc, nil
+}
+
+//yamlIndent the indentation of the first indented line, two spaces if there is none
+func yamlIndent(b []byte) int {
+	for _, line := range strings.Split(string(b), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed != "" && trimmed != line && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "- ") {
+			return len(line) - len(trimmed)
+		}
+	}
+
+	return 2
+}
+
+func encodeYAML(node *yamlv3.Node, indent int) (string, error) {
+	var buf bytes.Buffer
+
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(indent)
+
+	if err := enc.Encode(node); err != nil {
+		return "", err
+	}
+
+	err := enc.Close()
+	return buf.String(), err
+}
+
+func yamlKey(mapping *yamlv3.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+//findYAMLMapping the mapping at path, nil if it doesn't exist
+func findYAMLMapping(doc *yamlv3.Node, path []string) *yamlv3.Node {
+	node := doc.Content[0]
+
+	for _, key := range path {
+		i := yamlKey(node, key)
+		if i < 0 || node.Content[i+1].Kind != yamlv3.MappingNode {
+			return nil
+		}
+		node = node.Content[i+1]
+	}
+
+	return node
+}
+
+func sameYAML(a *yamlv3.Node, b *yamlv3.Node) bool {
+	var x, y interface{}
+
+	if a.Decode(&x) != nil || b.Decode(&y) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(x, y)
+}
+
+func mergeYAMLMapping(dst *yamlv3.Node, src *yamlv3.Node, path []string, indent int, changes *[]MergeChange) error {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		p := append(append([]string{}, path...), key.Value)
+
+		j := yamlKey(dst, key.Value)
+		if j < 0 {
+			dst.Content = append(dst.Content, key, value)
+			*changes = append(*changes, MergeChange{Path: p})
+			continue
+		}
+
+		existing := dst.Content[j+1]
+		if existing.Kind == yamlv3.MappingNode && value.Kind == yamlv3.MappingNode {
+			if err := mergeYAMLMapping(existing, value, p, indent, changes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if sameYAML(existing, value) {
+			continue
+		}
+
+		old, err := encodeYAML(existing, indent)
+		if err != nil {
+			return err
+		}
+
+		dst.Content[j+1] = value
+		*changes = append(*changes, MergeChange{Path: p, Existed: true, Old: old})
+	}
+
+	return nil
+}
+
+//unmergeYAML put back the values the recorded changes replaced and remove the keys sane added
+func unmergeYAML(doc *yamlv3.Node, changes []MergeChange) error {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		key := change.Path[len(change.Path)-1]
+
+		mapping := findYAMLMapping(doc, change.Path[:len(change.Path)-1])
+		if mapping == nil {
+			// removed by the user in the meantime
+			continue
+		}
+
+		j := yamlKey(mapping, key)
+
+		if !change.Existed {
+			if j >= 0 {
+				mapping.Content = append(mapping.Content[:j], mapping.Content[j+2:]...)
+			}
+			continue
+		}
+
+		old := &yamlv3.Node{}
+		if err := yamlv3.Unmarshal([]byte(change.Old), old); err != nil {
+			return err
+		}
+
+		if j >= 0 {
+			mapping.Content[j+1] = old.Content[0]
+		} else {
+			mapping.Content = append(mapping.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: key}, old.Content[0])
+		}
+	}
+
+	return nil
+}
+
+//mergeYAML merge the keys of content into a YAML file
+func mergeYAML(existing []byte, content []byte, previous []MergeChange) ([]byte, []MergeChange, error) {
+	doc, err := parseYAMLDocument(existing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := unmergeYAML(doc, previous); err != nil {
+		return nil, nil, err
+	}
+
+	src, err := parseYAMLDocument(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indent := yamlIndent(existing)
+	changes := make([]MergeChange, 0)
+
+	if err := mergeYAMLMapping(doc.Content[0], src.Content[0], []string{}, indent, &changes); err != nil {
+		return nil, nil, err
+	}
+
+	b, err := encodeYAML(doc, indent)
+	return []byte(b), changes, err
+}
+
+//unmergeYAMLContent remove the changes sane made from a merged YAML file. Returns whether anything is left.
+func unmergeYAMLContent(existing []byte, changes []MergeChange) ([]byte, bool, error) {
+	doc, err := parseYAMLDocument(existing)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := unmergeYAML(doc, changes); err != nil {
+		return nil, false, err
+	}
+
+	b, err := encodeYAML(doc, yamlIndent(existing))
+	return []byte(b), len(doc.Content[0].Content) != 0, err
+}